@@ -2,86 +2,149 @@ package main
 
 import (
 	"2links/internal/pkg/bot"
+	"2links/internal/pkg/clickqueue"
+	"2links/internal/pkg/config"
+	"2links/internal/pkg/geoip"
+	"2links/internal/pkg/health"
+	"2links/internal/pkg/lifecycle"
+	"2links/internal/pkg/metrics"
 	"2links/internal/pkg/saving"
 	"2links/internal/pkg/server"
+	"2links/internal/pkg/shortener"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"sync"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run() error {
 	err := godotenv.Load()
 	if err != nil {
 		log.Printf("ENVs were loaded not straightly")
 	}
 
-	token := os.Getenv("TELEGRAM_BOT_TOKEN")
-	if token == "" {
-		log.Panic("TELEGRAM_BOT_TOKEN is not set")
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
 	}
 
-	admToken := os.Getenv("ADMIN_BOT_TOKEN")
-	if admToken == "" {
-		log.Panic("ADMIN_BOT_TOKEN is not set")
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := prepareDatabase(cfg.Database); err != nil {
+			return err
+		}
+
+		runMigrate(cfg.Database.Driver, cfg.Database.Conn(), args[1:])
+		return nil
 	}
 
-	url := os.Getenv("MY_DOMAIN")
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("Invalid configuration:\n%w", err)
+	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := prepareDatabase(cfg.Database); err != nil {
+		return err
 	}
 
-	domain := os.Getenv("MY_DOMAIN")
-	if domain == "" {
-		domain = "http://localhost:" + port
+	db, err := saving.Open(cfg.Database.Driver, cfg.Database.Conn())
+	if err != nil {
+		return fmt.Errorf("Error connecting to database: %w", err)
 	}
 
-	dbType := os.Getenv("DB")
-	postgresDefault := os.Getenv("POSTGRES_DEFAULT")
-	postgresConn := os.Getenv("POSTGRES")
-	if dbType == "" || postgresDefault == "" || postgresConn == "" {
-		log.Panic("Envs weren't loaded")
+	defer db.Close()
+
+	db = metrics.InstrumentStore(db)
+
+	if cfg.Cache.Size > 0 {
+		cache := saving.NewCache(db, cfg.Cache.Size, cfg.Cache.TTL)
+		metrics.RegisterCache(cache)
+		db = cache
 	}
+	// to drop db
+	// db.Close()
+	// saving.DropDatabase("shortlinks", cfg.Database.Driver, cfg.Database.PostgresDefault)
 
-	err = saving.CreateDatabaseIfNotExists("shortlinks", dbType, postgresDefault)
+	generator, err := shortener.NewGenerator(cfg.Links.CodeGenerator, cfg.Links.CodeLength, cfg.Links.CodeSalt, db)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+
+	links := shortener.New(db, shortener.Config{
+		Generator:       generator,
+		LifetimeDays:    cfg.Links.DefaultLifetimeDays,
+		MaxLifetimeDays: cfg.Links.MaxLifetimeDays,
+	})
+
+	var geo *geoip.DB
+	if cfg.GeoIPPath != "" {
+		geo, err = geoip.Open(cfg.GeoIPPath)
+		if err != nil {
+			return err
+		}
+
+		defer geo.Close()
 	}
 
-	db, err := saving.CreateDB(dbType, postgresConn)
+	trustedProxies, err := server.ParseTrustedProxies(cfg.TrustedProxies)
 	if err != nil {
-		log.Panic("Error connecting to database")
+		return err
 	}
 
-	defer db.Db.Close()
-	// to drop db
-	// db.Db.Close()
-	// saving.DropDatabase("shortlinks", dbType, postgresDefault)
-
-	var wg sync.WaitGroup
-	wg.Add(3)
-
-	go func() {
-		defer wg.Done()
-		srv := server.NewServer(db.Db, domain)
-		log.Printf("Starting server on port %s", port)
-		srv.Start(port, db.Db)
-	}()
-
-	go func() {
-		defer wg.Done()
-		log.Println("Starting Telegram bot")
-		bot.StartBot(url, db, token)
-	}()
-
-	go func() {
-		defer wg.Done()
-		log.Println("Starting Admin bot")
-		bot.StartAdminBot(admToken, db)
-	}()
-
-	wg.Wait()
+	clicks := clickqueue.New(db, cfg.Clicks.QueueSize, cfg.Clicks.BatchSize, cfg.Clicks.FlushInterval)
+	metrics.RegisterClickQueue(clicks)
+
+	// Deferred after db.Close, so pending clicks are flushed while the
+	// database is still open.
+	defer clicks.Close()
+
+	health.Watch(bot.UserPoller, bot.AdminPoller)
+
+	srv := server.NewServer(db, links, cfg.Domain, cfg.Links.RedirectStatus, geo, trustedProxies, clicks)
+
+	var userWebhook, adminWebhook *bot.Webhook
+	if cfg.Telegram.WebhookURL != "" {
+		userWebhook = bot.NewWebhook(cfg.Telegram.WebhookURL, cfg.Telegram.Token, cfg.Telegram.WebhookSecret)
+		adminWebhook = bot.NewWebhook(cfg.Telegram.WebhookURL, cfg.Telegram.AdminToken, cfg.Telegram.WebhookSecret)
+		srv.HandleWebhook(userWebhook.Path(), userWebhook)
+		srv.HandleWebhook(adminWebhook.Path(), adminWebhook)
+	}
+
+	manager := lifecycle.New()
+	manager.Go("HTTP server", func(ctx context.Context) error {
+		return srv.Start(ctx, cfg.Port)
+	})
+	manager.Go("metrics server", func(ctx context.Context) error {
+		return metrics.Serve(ctx, cfg.MetricsPort)
+	})
+	manager.Go("Telegram bot", func(ctx context.Context) error {
+		return bot.StartBot(ctx, cfg, db, links, userWebhook)
+	})
+	manager.Go("admin bot", func(ctx context.Context) error {
+		return bot.StartAdminBot(ctx, cfg, db, adminWebhook)
+	})
+
+	return manager.Wait()
+}
+
+func prepareDatabase(cfg config.Database) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Driver == saving.DriverPostgres {
+		return saving.CreateDatabaseIfNotExists("shortlinks", cfg.Driver, cfg.PostgresDefault)
+	}
+
+	return nil
 }
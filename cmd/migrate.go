@@ -0,0 +1,65 @@
+package main
+
+import (
+	"2links/internal/pkg/saving"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = "Usage: migrate up | down [steps] | status"
+
+func runMigrate(dbType string, dbConn string, args []string) {
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	db, err := saving.Connect(dbType, dbConn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		count, err := db.MigrateUp()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Applied %d migration(s)", count)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+
+		count, err := db.MigrateDown(steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Reverted %d migration(s)", count)
+
+	case "status":
+		migrations, err := db.MigrationStatus()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, m := range migrations {
+			status := "pending"
+			if m.Applied {
+				status = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stdout, "%04d_%s\t%s\n", m.Version, m.Name, status)
+		}
+
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
@@ -0,0 +1,74 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+
+	keyPrefix    = "2l"
+	prefixLength = 8
+	secretLength = 32
+	alphabet     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite
+}
+
+func Allows(scope, required string) bool {
+	return scope == ScopeWrite || scope == required
+}
+
+func Generate() (key string, prefix string, hash string, err error) {
+	prefix, err = randomString(prefixLength)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	secret, err := randomString(secretLength)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Failed to hash API key: %w", err)
+	}
+
+	key = fmt.Sprintf("%s_%s_%s", keyPrefix, prefix, secret)
+	return key, prefix, string(hashed), nil
+}
+
+func Parse(key string) (prefix string, secret string, ok bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[1]) != prefixLength || len(parts[2]) != secretLength {
+		return "", "", false
+	}
+
+	return parts[1], parts[2], true
+}
+
+func Check(secret, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("Failed to generate random string: %w", err)
+		}
+		b[i] = alphabet[idx.Int64()]
+	}
+
+	return string(b), nil
+}
@@ -1,7 +1,10 @@
 package bot
 
 import (
+	"2links/internal/pkg/config"
+	"2links/internal/pkg/metrics"
 	"2links/internal/pkg/saving"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -21,18 +24,23 @@ const (
 
 var adminAuthorized sync.Map
 
-func StartAdminBot(token string, db *saving.DB) {
-	bot, err := tgbotapi.NewBotAPI(token)
+func StartAdminBot(ctx context.Context, cfg *config.Config, db saving.Store, webhook *Webhook) error {
+	adminPasswordHash, err := readHashFromFile(cfg.Telegram.AdminPasswordHashFile)
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("Failed to load admin password hash: %w", err)
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithClient(cfg.Telegram.AdminToken, tgbotapi.APIEndpoint,
+		pollerClient{name: AdminPoller, next: metrics.TelegramClient("admin")})
+	if err != nil {
+		return fmt.Errorf("Failed to authorize admin bot: %w", err)
 	}
 
 	bot.Debug = true
 	log.Printf("Admin bot authorized on account %s", bot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
+	updates, stop := receiveUpdates(ctx, bot, AdminPoller, webhook)
+	defer stop()
 
 	keyboard := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonSuspiciousLinks)),
@@ -41,21 +49,17 @@ func StartAdminBot(token string, db *saving.DB) {
 		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonStatistics)),
 	)
 
-	for update := range updates {
+	handle := func(update tgbotapi.Update) {
 		if update.Message != nil {
 			chatID := update.Message.Chat.ID
 			text := update.Message.Text
-			adminPasswordHash, err := readHashFromFile("internal/pkg/bot/adminPasswordHash.txt")
-			if err != nil {
-				log.Printf("Error reading file with hash: %v", err)
-			}
 
 			isAuthorized, _ := adminAuthorized.Load(chatID)
 			if authorized, ok := isAuthorized.(bool); !ok || !authorized {
 				if text == "/start" || text == "/help" {
 					bot.Send(tgbotapi.NewMessage(chatID, "Введите пароль администратора для доступа."))
 					adminAuthorized.Store(chatID, false)
-					continue
+					return
 				}
 
 				if checkPasswordHash(text, adminPasswordHash) {
@@ -67,7 +71,7 @@ func StartAdminBot(token string, db *saving.DB) {
 					bot.Send(tgbotapi.NewMessage(chatID, "Неверный пароль. Попробуйте снова."))
 				}
 
-				continue
+				return
 			}
 
 			switch {
@@ -92,10 +96,25 @@ func StartAdminBot(token string, db *saving.DB) {
 			}
 		}
 	}
+
+	workers := newDispatcher("admin", cfg.Telegram.Workers, handle)
+	defer workers.stop()
+
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ctx.Done():
+			return nil
+		case update = <-updates:
+		}
+
+		metrics.BotUpdate("admin", updateCommand(update))
+		workers.dispatch(ctx, update)
+	}
 }
 
-func handleReviews(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
-	reviews, err := saving.GetReviews(db.Db)
+func handleReviews(bot *tgbotapi.BotAPI, db saving.Store, chatID int64) {
+	reviews, err := db.GetReviews()
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении списка отзывов"))
 		log.Printf("Error fetching reviews: %v", err)
@@ -115,8 +134,8 @@ func handleReviews(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
 	bot.Send(tgbotapi.NewMessage(chatID, message))
 }
 
-func handleGrade(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
-	grade, err := saving.GetGrade(db.Db)
+func handleGrade(bot *tgbotapi.BotAPI, db saving.Store, chatID int64) {
+	grade, err := db.GetGrade()
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении средней оценки."))
 		log.Printf("Error fetching grade: %v", err)
@@ -126,8 +145,8 @@ func handleGrade(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
 	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Средняя оценка сервиса: %.3f", grade)))
 }
 
-func handleSuspectLinks(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
-	links, err := saving.GetSuspectLinks(db.Db)
+func handleSuspectLinks(bot *tgbotapi.BotAPI, db saving.Store, chatID int64) {
+	links, err := db.GetSuspectLinks()
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении списка подозрительных ссылок."))
 		log.Printf("Error fetching suspected links: %v", err)
@@ -145,11 +164,13 @@ func handleSuspectLinks(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
 			link.ShortURL, link.OriginalURL, link.ShortURL)
 	}
 
-	bot.Send(tgbotapi.NewMessage(chatID, message))
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.DisableWebPagePreview = true
+	bot.Send(msg)
 }
 
-func handleDeleteLink(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64, link string) {
-	err := saving.DeleteSuspectLink(db.Db, link)
+func handleDeleteLink(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, link string) {
+	err := db.DeleteSuspectLink(link)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при удалении ссылки."))
 		log.Printf("Error deleting link: %v", err)
@@ -159,8 +180,8 @@ func handleDeleteLink(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64, link st
 	bot.Send(tgbotapi.NewMessage(chatID, "Ссылка успешно удалена."))
 }
 
-func handleStatistics(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
-	stats, err := saving.GetSummaryStatistics(db.Db)
+func handleStatistics(bot *tgbotapi.BotAPI, db saving.Store, chatID int64) {
+	stats, err := db.GetSummaryStatistics()
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении статистики."))
 		log.Printf("Error fetching statistics: %v", err)
@@ -174,6 +195,12 @@ func handleStatistics(bot *tgbotapi.BotAPI, db *saving.DB, chatID int64) {
 		"Истёкшие ссылки: %d\n",
 		stats.Users, stats.Links, stats.Clicks, stats.ExpiredLinks)
 
+	if cache, ok := db.(*saving.Cache); ok {
+		cacheStats := cache.Stats()
+		message += fmt.Sprintf("Кэш ссылок: %d записей, попаданий %d, промахов %d\n",
+			cacheStats.Size, cacheStats.Hits, cacheStats.Misses)
+	}
+
 	bot.Send(tgbotapi.NewMessage(chatID, message))
 }
 
@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"2links/internal/pkg/apikey"
+	"2links/internal/pkg/saving"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func handleAPIKeys(bot *tgbotapi.BotAPI, db saving.Store, chatID int64) {
+	keys, err := db.ListAPIKeys(chatID)
+	if err != nil {
+		log.Printf("Error fetching API keys: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении ключей. Попробуйте позже."))
+		return
+	}
+
+	message := "У вас пока нет ключей API."
+	if len(keys) > 0 {
+		message = "Ваши ключи API:\n"
+	}
+
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup()
+	for _, key := range keys {
+		lastUsed := "никогда"
+		if key.LastUsedAt.Valid {
+			lastUsed = formatTime(key.LastUsedAt.Time)
+		}
+
+		message += fmt.Sprintf(
+			"\nКлюч: %s…\nДоступ: %s\nСоздан: %s\nИспользован: %s\n",
+			key.Prefix, scopeTitle(key.Scope), formatTime(key.CreatedAt), lastUsed,
+		)
+
+		inlineKeyboard.InlineKeyboard = append(
+			inlineKeyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("Отозвать %s", key.Prefix),
+				fmt.Sprintf("revokekey:%s", key.Prefix),
+			)),
+		)
+	}
+
+	inlineKeyboard.InlineKeyboard = append(
+		inlineKeyboard.InlineKeyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Новый ключ (чтение)", "newkey:"+apikey.ScopeRead),
+			tgbotapi.NewInlineKeyboardButtonData("Новый ключ (запись)", "newkey:"+apikey.ScopeWrite),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ReplyMarkup = inlineKeyboard
+	bot.Send(msg)
+}
+
+func handleIssueAPIKey(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, scope string) {
+	if !apikey.ValidScope(scope) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Неизвестный тип доступа."))
+		return
+	}
+
+	key, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		log.Printf("Error generating API key: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при создании ключа. Попробуйте позже."))
+		return
+	}
+
+	err = db.SaveAPIKey(chatID, prefix, hash, scope)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при создании ключа. Попробуйте позже."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Ваш новый ключ (%s):\n`%s`\n\nСохраните его: больше он показан не будет. Передавайте его в заголовке `Authorization: Bearer <ключ>`.",
+		scopeTitle(scope), key,
+	))
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+func handleRevokeAPIKey(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, prefix string) {
+	err := db.DeleteAPIKey(chatID, prefix)
+	if err != nil {
+		log.Printf("Error revoking API key: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось отозвать ключ. Возможно, он уже отозван."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ключ %s отозван.", prefix)))
+}
+
+func scopeTitle(scope string) string {
+	if scope == apikey.ScopeWrite {
+		return "чтение и запись"
+	}
+
+	return "только чтение"
+}
@@ -1,11 +1,14 @@
 package bot
 
 import (
+	"2links/internal/pkg/config"
+	"2links/internal/pkg/metrics"
 	"2links/internal/pkg/saving"
 	"2links/internal/pkg/shortener"
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,22 +23,40 @@ const (
 	buttonComplaint = "Пожаловаться на ссылку"
 	buttonFeedback  = "Оставить обратную связь"
 	buttonHelp      = "Получить помощь"
+	buttonSkip      = "Пропустить"
+	buttonNoPass    = "Без пароля"
+
+	dateInputFormat = "DD-MM-YYYY [HH:MM] [часовой пояс]"
+
+	aliasQuestion = "Хотите выбрать свой адрес, например promo-fall? Введите его или нажмите «Пропустить»"
+	utmQuestion   = "Добавить UTM-метки? Отправьте их в формате source=tg medium=post campaign=fall (term и content — по желанию, значения с пробелами возьмите в кавычки: campaign=\"осенняя распродажа\", preset=имя сохранит шаблон), выберите шаблон или нажмите «Пропустить»"
 )
 
-var userStates sync.Map
+var (
+	userStates   sync.Map
+	pendingLinks sync.Map
+	pendingUTM   sync.Map
 
-func StartBot(url string, db *saving.DB, token string) {
-	bot, err := tgbotapi.NewBotAPI(token)
+	skipKeyboard = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonSkip)),
+	)
+
+	passwordKeyboard = tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonSkip), tgbotapi.NewKeyboardButton(buttonNoPass)),
+	)
+)
+
+func StartBot(ctx context.Context, cfg *config.Config, db saving.Store, links *shortener.Shortener, webhook *Webhook) error {
+	url := cfg.Domain
+	bot, err := tgbotapi.NewBotAPIWithClient(cfg.Telegram.Token, tgbotapi.APIEndpoint,
+		pollerClient{name: UserPoller, next: metrics.TelegramClient("user")})
 	if err != nil {
-		log.Panic(err)
+		return fmt.Errorf("Failed to authorize Telegram bot: %w", err)
 	}
 
 	bot.Debug = true
 	log.Printf("Authorized on account %s", bot.Self.UserName)
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
 	keyboard := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonShorten)),
 		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonMyLinks)),
@@ -48,36 +69,132 @@ func StartBot(url string, db *saving.DB, token string) {
 	options := []string{"Плохо", "Так себе", "Хорошо", "Здорово", "Супер"}
 	isAnonymous := false
 
-	updates := bot.GetUpdatesChan(u)
-	for update := range updates {
+	updates, stop := receiveUpdates(ctx, bot, UserPoller, webhook)
+	defer stop()
+
+	handle := func(update tgbotapi.Update) {
+		var err error
 		if update.CallbackQuery != nil {
 			callbackData := update.CallbackQuery.Data
 			chatID := update.CallbackQuery.Message.Chat.ID
 			var message string
 
 			switch {
-			case len(callbackData) > 7 && callbackData[:7] == "delete:":
-				shortLink := callbackData[7:]
-				err := saving.DeleteLink(db.Db, shortLink)
-				if err != nil {
-					log.Printf("Error deleting link: %v", err)
-				}
+			case strings.HasPrefix(callbackData, "delete:"):
+				handleDeletePrompt(bot, db, chatID, strings.TrimPrefix(callbackData, "delete:"))
+
+			case strings.HasPrefix(callbackData, "confirmdelete:"):
+				handleConfirmDelete(bot, db, chatID, strings.TrimPrefix(callbackData, "confirmdelete:"), keyboard)
+
+			case strings.HasPrefix(callbackData, "deleteutm:"):
+				handleDeleteUTMPreset(bot, db, chatID, strings.TrimPrefix(callbackData, "deleteutm:"))
+
+			case strings.HasPrefix(callbackData, "newkey:"):
+				handleIssueAPIKey(bot, db, chatID, strings.TrimPrefix(callbackData, "newkey:"))
 
-				message = "Ссылка удалена"
+			case strings.HasPrefix(callbackData, "revokekey:"):
+				handleRevokeAPIKey(bot, db, chatID, strings.TrimPrefix(callbackData, "revokekey:"))
+
+			case strings.HasPrefix(callbackData, "manage:"):
+				handleManageMenu(bot, db, chatID, url, strings.TrimPrefix(callbackData, "manage:"))
+
+			case callbackData == "back":
+				message = "Возвращаемся в основное меню"
 				msg := tgbotapi.NewMessage(chatID, message)
 				msg.ReplyMarkup = keyboard
 				bot.Send(msg)
 
-			case callbackData == "back":
-				message = "Возвращаемся в основное меню"
+			case strings.HasPrefix(callbackData, "password:"):
+				shortURL := strings.TrimPrefix(callbackData, "password:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_password_%s", shortURL))
+				message = fmt.Sprintf("Введите новый пароль для ссылки %s или снимите защиту:", shortURL)
+				msg := tgbotapi.NewMessage(chatID, message)
+				msg.ReplyMarkup = passwordKeyboard
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "budget:"):
+				shortURL := strings.TrimPrefix(callbackData, "budget:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_budget_%s", shortURL))
+				message = fmt.Sprintf("Сколько переходов разрешить по ссылке %s? Например, 1 для одноразовой ссылки или 0, чтобы снять ограничение:", shortURL)
+				msg := tgbotapi.NewMessage(chatID, message)
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "retarget:"):
+				shortURL := strings.TrimPrefix(callbackData, "retarget:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_url_%s", shortURL))
+				message = fmt.Sprintf("Введите новый адрес, на который будет вести ссылка %s. Короткая ссылка и QR-код останутся прежними:", shortURL)
 				msg := tgbotapi.NewMessage(chatID, message)
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "redirect:"):
+				handleRedirectMenu(bot, chatID, strings.TrimPrefix(callbackData, "redirect:"))
+
+			case strings.HasPrefix(callbackData, "setredirect:"):
+				handleSetRedirect(bot, db, chatID, strings.TrimPrefix(callbackData, "setredirect:"))
+
+			case strings.HasPrefix(callbackData, "passthrough:"):
+				msg := tgbotapi.NewMessage(chatID, togglePassthrough(db, chatID, strings.TrimPrefix(callbackData, "passthrough:")))
 				msg.ReplyMarkup = keyboard
 				bot.Send(msg)
 
+			case strings.HasPrefix(callbackData, "rules:"):
+				handleRulesMenu(bot, db, chatID, strings.TrimPrefix(callbackData, "rules:"))
+
+			case strings.HasPrefix(callbackData, "setrule:"):
+				target := strings.TrimPrefix(callbackData, "setrule:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_rule_%s", target))
+				msg := tgbotapi.NewMessage(chatID, "Введите адрес для этого устройства, например ссылку на App Store или Google Play, или 0, чтобы вести на основной адрес:")
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "setcountry:"):
+				shortURL := strings.TrimPrefix(callbackData, "setcountry:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_country_rule_%s", shortURL))
+				msg := tgbotapi.NewMessage(chatID, "Введите двухбуквенный код страны и адрес через пробел, например RU https://example.ru, или код и 0, чтобы удалить правило:")
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "variants:"):
+				handleVariantsMenu(bot, db, chatID, strings.TrimPrefix(callbackData, "variants:"))
+
+			case strings.HasPrefix(callbackData, "addvariant:"):
+				shortURL := strings.TrimPrefix(callbackData, "addvariant:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_variant_%s", shortURL))
+				msg := tgbotapi.NewMessage(chatID, "Введите адрес варианта и, через пробел, его вес от 1 до 100, например https://example.com/b 50. Без веса вариант получит вес 1:")
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "delvariant:"):
+				msg := tgbotapi.NewMessage(chatID, deleteVariant(db, chatID, strings.TrimPrefix(callbackData, "delvariant:")))
+				msg.ReplyMarkup = keyboard
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "stats:"):
+				msg := tgbotapi.NewMessage(chatID, linkStats(db, chatID, strings.TrimPrefix(callbackData, "stats:")))
+				msg.ReplyMarkup = keyboard
+				msg.DisableWebPagePreview = true
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "history:"):
+				msg := tgbotapi.NewMessage(chatID, linkHistory(db, chatID, strings.TrimPrefix(callbackData, "history:")))
+				msg.ReplyMarkup = keyboard
+				msg.DisableWebPagePreview = true
+				bot.Send(msg)
+
+			case strings.HasPrefix(callbackData, "activate:"):
+				shortURL := strings.TrimPrefix(callbackData, "activate:")
+				userStates.Store(chatID, fmt.Sprintf("awaiting_activation_%s", shortURL))
+				message = fmt.Sprintf("Введите время запуска ссылки %s в формате %s, например 01-09-2025 10:00 Europe/Moscow. По умолчанию используется московское время. Отправьте 0, чтобы ссылка работала сразу:", shortURL, dateInputFormat)
+				msg := tgbotapi.NewMessage(chatID, message)
+				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+				bot.Send(msg)
+
 			case len(callbackData) > 7 && callbackData[:7] == "update:":
 				shortURL := callbackData[7:]
 				userStates.Store(chatID, fmt.Sprintf("awaiting_expiry_%s", shortURL))
-				message = fmt.Sprintf("Введите новый срок хранения для ссылки %s в формате DD-MM-YYYY:", shortURL)
+				message = fmt.Sprintf("Введите новый срок хранения для ссылки %s в формате %s:", shortURL, dateInputFormat)
 				msg := tgbotapi.NewMessage(chatID, message)
 				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
 				bot.Send(msg)
@@ -87,7 +204,7 @@ func StartBot(url string, db *saving.DB, token string) {
 			var msg tgbotapi.MessageConfig
 			answer := update.PollAnswer
 			userChoiceIndex := answer.OptionIDs[0]
-			err = saving.SaveFeedback(db.Db, userChoiceIndex+2, answer.User.ID)
+			err = db.SaveFeedback(userChoiceIndex+2, answer.User.ID)
 			fmt.Println(userChoiceIndex)
 			if userChoiceIndex == 4 {
 				msg = tgbotapi.NewMessage(answer.User.ID, "Спасибо за вашу оценку!")
@@ -108,8 +225,8 @@ func StartBot(url string, db *saving.DB, token string) {
 			switch update.Message.Text {
 
 			case "/start":
-				if !saving.UserInBase(db.Db, chatID) {
-					err = saving.AddUser(db.Db, chatID)
+				if !db.UserInBase(chatID) {
+					err = db.AddUser(chatID)
 					if err != nil {
 						log.Printf("Error saving user %v", err)
 					}
@@ -119,7 +236,15 @@ func StartBot(url string, db *saving.DB, token string) {
 				msg.ReplyMarkup = keyboard
 
 			case "/help", buttonHelp:
-				msg = tgbotapi.NewMessage(chatID, "Я могу помочь с сокращением ссылок:\n/start - Запустить\n/feedback - Поделиться мнением о боте\n/help - Узнать, что я умею")
+				msg = tgbotapi.NewMessage(chatID, "Я могу помочь с сокращением ссылок:\n/start - Запустить\n/feedback - Поделиться мнением о боте\n/apikeys - Управлять ключами HTTP API\n/utm - Управлять шаблонами UTM-меток\n/help - Узнать, что я умею")
+
+			case "/apikeys":
+				handleAPIKeys(bot, db, chatID)
+				return
+
+			case "/utm":
+				handleUTMPresets(bot, db, chatID)
+				return
 
 			case "/feedback", buttonFeedback:
 				poll := tgbotapi.SendPollConfig{
@@ -135,13 +260,13 @@ func StartBot(url string, db *saving.DB, token string) {
 				}
 
 			case buttonMyLinks:
-				stats, err := saving.GetClicksByUser(db.Db, chatID)
+				stats, err := db.GetClicksByUser(chatID)
 				if err != nil {
 					log.Printf("Error fetching clicks: %v", err)
 					bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении статистики. Попробуйте позже."))
 				}
 
-				links, err := saving.ShowMyLinks(db.Db, chatID)
+				links, err := db.ShowMyLinks(chatID)
 				if err != nil {
 					log.Printf("Error fetching links: %v", err)
 					bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении ваших ссылок. Попробуйте позже."))
@@ -161,41 +286,56 @@ func StartBot(url string, db *saving.DB, token string) {
 						clicks = statsData.Clicks
 					}
 
-					formattedTime := link.CreatedAt.Add(3 * time.Hour).Format("02.01.2006, 15:04")
-					expiryTime := link.ExpiresAt.Format("02.01.2006, 15:04")
+					details := ""
+					if link.PasswordHash != "" {
+						details += "Защищена паролем\n"
+					}
+
+					if link.MaxClicks > 0 {
+						details += fmt.Sprintf("Осталось переходов: %d из %d\n", link.ClicksLeft, link.MaxClicks)
+					}
+
+					if link.RedirectStatus != 0 {
+						details += fmt.Sprintf("Перенаправление: %s\n", redirectTitle(link.RedirectStatus))
+					}
+
+					if link.Passthrough {
+						details += "Передаёт параметры и путь\n"
+					}
+
+					if link.Pending(time.Now()) {
+						details += fmt.Sprintf("Запуск: %s (МСК)\n", formatTime(link.ActivatesAt))
+					}
+
+					formattedTime := formatTime(link.CreatedAt)
+					expiryTime := formatTime(link.ExpiresAt)
 					daysLeft := int(time.Until(link.ExpiresAt).Hours() / 24)
 					if daysLeft < 0 {
 						message += fmt.Sprintf(
-							"Ссылка: %s\nОригинал: %s\nПереходов: %d\nСоздана: %s\nСтатус: Просрочена\n\n",
-							url+link.ShortURL, link.OriginalURL, clicks, formattedTime,
+							"Ссылка: %s\nОригинал: %s\nПереходов: %d\n%sСоздана: %s\nСтатус: Просрочена\n\n",
+							url+link.ShortURL, link.OriginalURL, clicks, details, formattedTime,
 						)
 					} else {
 						message += fmt.Sprintf(
-							"Ссылка: [%s](%s)\nОригинал: %s\nПереходов: %d\nСоздана: %s\nИстекает: %s\nОсталось: %d дней\nQR-код: [/qr_%s](%s)\n\n",
-							url+link.ShortURL, url+link.ShortURL, link.OriginalURL, clicks, formattedTime, expiryTime, daysLeft, link.ShortURL, fmt.Sprintf("/qr/%s", link.ShortURL),
+							"Ссылка: [%s](%s)\nОригинал: %s\nПереходов: %d\n%sСоздана: %s\nИстекает: %s\nОсталось: %d дней\nQR-код: [/qr_%s](%s)\n\n",
+							url+link.ShortURL, url+link.ShortURL, link.OriginalURL, clicks, details, formattedTime, expiryTime, daysLeft, link.ShortURL, fmt.Sprintf("/qr/%s", link.ShortURL),
 						)
 
 					}
 
-					deleteButton := tgbotapi.NewInlineKeyboardButtonData(
-						fmt.Sprintf("Удалить %s", link.ShortURL),
-						fmt.Sprintf("delete:%s", link.ShortURL),
-					)
-
-					updateButton := tgbotapi.NewInlineKeyboardButtonData(
-						fmt.Sprintf("Изменить срок %s", link.ShortURL),
-						fmt.Sprintf("update:%s", link.ShortURL),
-					)
-
 					inlineKeyboard.InlineKeyboard = append(
 						inlineKeyboard.InlineKeyboard,
-						tgbotapi.NewInlineKeyboardRow(deleteButton, updateButton),
+						tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+							fmt.Sprintf("Управление %s", link.ShortURL),
+							fmt.Sprintf("manage:%s", link.ShortURL),
+						)),
 					)
 				}
 
 				msg := tgbotapi.NewMessage(chatID, message)
 				msg.ReplyMarkup = inlineKeyboard
 				msg.ParseMode = "Markdown"
+				msg.DisableWebPagePreview = true
 				bot.Send(msg)
 
 			case buttonShorten:
@@ -204,7 +344,7 @@ func StartBot(url string, db *saving.DB, token string) {
 				userStates.Store(chatID, "awaiting_link")
 
 			case buttonComplaint:
-				msg = tgbotapi.NewMessage(chatID, "Введите ссылку, на которую хотите пожаловаться в формате 2lnx.ru/xxxx")
+				msg = tgbotapi.NewMessage(chatID, "Введите ссылку, на которую хотите пожаловаться в формате "+cfg.ShortLinkPrefix()+"xxxx")
 				msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
 				userStates.Store(chatID, "awaiting_bad_link")
 
@@ -212,25 +352,121 @@ func StartBot(url string, db *saving.DB, token string) {
 				if ok && state == "awaiting_link" {
 					longLink := update.Message.Text
 					if shortener.CheckValidacy(longLink) {
-						shortLink, err := shortener.СreateShortLink(db, chatID, longLink)
-						if err != nil {
-							bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при создании короткой ссылки. Попробуйте позже."))
-							log.Printf("Error creating short link: %v", err)
-							continue
-						}
+						pendingLinks.Store(chatID, longLink)
+						userStates.Store(chatID, "awaiting_utm")
+						msg = tgbotapi.NewMessage(chatID, utmQuestion)
+						msg.ReplyMarkup = utmKeyboard(db, chatID)
+					} else {
+						msg = tgbotapi.NewMessage(chatID, "Эта ссылка не действительня, попробуйте другую")
+						msg.ReplyMarkup = keyboard
+						userStates.Delete(chatID)
+					}
+
+				} else if ok && (state == "awaiting_utm" || state == "awaiting_utm_confirm") {
+					text := update.Message.Text
+					if text == buttonConfirm && state == "awaiting_utm_confirm" {
+						preview, _ := pendingUTM.LoadAndDelete(chatID)
+						pendingLinks.Store(chatID, preview)
+						text = buttonSkip
+					}
 
-						msg = tgbotapi.NewMessage(chatID, "Вот ваша сокращённая ссылка: "+url+shortLink)
+					if text == buttonSkip {
+						pendingUTM.Delete(chatID)
+						userStates.Store(chatID, "awaiting_alias")
+						msg = tgbotapi.NewMessage(chatID, aliasQuestion)
+						msg.ReplyMarkup = skipKeyboard
+						break
+					}
 
+					longLink, _ := pendingLinks.Load(chatID)
+					preview, message := buildUTMPreview(db, chatID, longLink.(string), text)
+					msg = tgbotapi.NewMessage(chatID, message)
+					msg.DisableWebPagePreview = true
+					if preview == "" {
+						msg.ReplyMarkup = utmKeyboard(db, chatID)
+						break
+					}
+
+					pendingUTM.Store(chatID, preview)
+					userStates.Store(chatID, "awaiting_utm_confirm")
+					msg.ReplyMarkup = confirmKeyboard
+
+				} else if ok && state == "awaiting_alias" {
+					longLink, _ := pendingLinks.Load(chatID)
+					alias := update.Message.Text
+					if alias == buttonSkip {
+						alias = ""
+					}
+
+					shortLink, err := links.CreateShortLink(saving.NewLink{UserID: chatID, ShortURL: alias, OriginalURL: longLink.(string)})
+					switch {
+					case errors.Is(err, shortener.ErrAliasInvalid):
+						msg = tgbotapi.NewMessage(chatID, "Адрес должен быть длиной от 3 до 32 символов и состоять из латинских букв, цифр, «-» и «_». Попробуйте другой")
+						msg.ReplyMarkup = skipKeyboard
+
+					case errors.Is(err, shortener.ErrAliasReserved):
+						msg = tgbotapi.NewMessage(chatID, "Этот адрес зарезервирован, попробуйте другой")
+						msg.ReplyMarkup = skipKeyboard
+
+					case errors.Is(err, shortener.ErrAliasTaken):
+						msg = tgbotapi.NewMessage(chatID, "Этот адрес уже занят, попробуйте другой")
+						msg.ReplyMarkup = skipKeyboard
+
+					case err != nil:
+						log.Printf("Error creating short link: %v", err)
+						msg = tgbotapi.NewMessage(chatID, "Ошибка при создании короткой ссылки. Попробуйте позже.")
+						msg.ReplyMarkup = keyboard
+						pendingLinks.Delete(chatID)
+						userStates.Delete(chatID)
+
+					default:
+						msg = tgbotapi.NewMessage(chatID, "Вот ваша сокращённая ссылка: "+url+shortLink+"\n\nХотите защитить её паролем? Введите пароль или нажмите «Пропустить»")
+						msg.DisableWebPagePreview = true
+						msg.ReplyMarkup = skipKeyboard
+						pendingLinks.Delete(chatID)
+						userStates.Store(chatID, fmt.Sprintf("awaiting_password_%s", shortLink))
+					}
+
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_budget_") {
+					shortURL := strings.TrimPrefix(state.(string), "awaiting_budget_")
+					maxClicks, err := strconv.Atoi(strings.TrimSpace(update.Message.Text))
+					if err != nil || maxClicks < 0 {
+						msg := tgbotapi.NewMessage(chatID, "Введите целое неотрицательное число.")
+						bot.Send(msg)
+						break
+					}
+
+					var message string
+					err = db.SetClickBudget(chatID, shortURL, maxClicks)
+					if err != nil {
+						log.Printf("Error setting click budget: %v", err)
+						message = "Не удалось изменить лимит. Убедитесь, что ссылка существует."
+					} else if maxClicks == 0 {
+						message = "Ограничение на число переходов снято."
+					} else if link, err := db.GetUserLink(chatID, shortURL); err == nil {
+						message = fmt.Sprintf("Лимит установлен: %d переход(ов), осталось %d.", maxClicks, link.ClicksLeft)
 					} else {
-						msg = tgbotapi.NewMessage(chatID, "Эта ссылка не действительня, попробуйте другую")
+						message = fmt.Sprintf("Лимит установлен: %d переход(ов).", maxClicks)
 					}
 
-					msg.ReplyMarkup = keyboard
 					userStates.Delete(chatID)
+					msg = tgbotapi.NewMessage(chatID, message)
+					msg.ReplyMarkup = keyboard
+
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_password_") {
+					shortURL := strings.TrimPrefix(state.(string), "awaiting_password_")
+					message, done := handleLinkPassword(bot, db, chatID, shortURL, update.Message)
+					msg = tgbotapi.NewMessage(chatID, message)
+					if done {
+						msg.ReplyMarkup = keyboard
+						userStates.Delete(chatID)
+					} else {
+						msg.ReplyMarkup = passwordKeyboard
+					}
 
 				} else if ok && state == "awaiting_feedback_details" {
 					msg = tgbotapi.NewMessage(chatID, "Спасибо за ваш отзыв!")
-					err = saving.SaveReview(db.Db, update.Message.Text, chatID)
+					err = db.SaveReview(update.Message.Text, chatID)
 					if err != nil {
 						log.Printf("Error saving review: %v", err)
 					}
@@ -241,15 +477,15 @@ func StartBot(url string, db *saving.DB, token string) {
 				} else if ok && state == "awaiting_bad_link" {
 					var linkID int
 					var message string
-					if strings.HasPrefix(update.Message.Text, "2lnx.ru/") {
-						badLink := update.Message.Text[8:]
-						linkID, err = saving.FindLink(db.Db, badLink)
+					text := strings.TrimPrefix(strings.TrimPrefix(update.Message.Text, "https://"), "http://")
+					if badLink, found := strings.CutPrefix(text, cfg.ShortLinkPrefix()); found {
+						linkID, err = db.FindLink(badLink)
 						if err != nil {
 							log.Printf("Error finding link: %v", err)
 						} else if linkID == 0 {
 							message = "Ссылка не найдена"
 						} else {
-							err = saving.SuspectLink(db.Db, linkID, badLink)
+							err = db.SuspectLink(linkID, badLink)
 							message = "Спасибо за обращение, мы проверим эту ссылку"
 						}
 					} else {
@@ -260,36 +496,90 @@ func StartBot(url string, db *saving.DB, token string) {
 					msg.ReplyMarkup = keyboard
 					userStates.Delete(chatID)
 
-				} else if ok && strings.HasPrefix(state.(string), "awaiting_expiry_") {
-					threashold := os.Getenv("MAX_LIFETIME")
-					if threashold == "" {
-						threashold = "730"
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_url_") {
+					shortURL := strings.TrimPrefix(state.(string), "awaiting_url_")
+					newURL := strings.TrimSpace(update.Message.Text)
+					if !shortener.CheckValidacy(newURL) {
+						msg := tgbotapi.NewMessage(chatID, "Эта ссылка не действительна, введите другую")
+						bot.Send(msg)
+						break
 					}
 
-					threasholdDays, err := strconv.Atoi(threashold)
+					var message string
+					err = db.UpdateLinkURL(chatID, shortURL, newURL)
 					if err != nil {
-						log.Printf("Error converting lifetime: %v", err)
-						break
+						log.Printf("Error updating link destination: %v", err)
+						message = "Не удалось изменить адрес. Убедитесь, что ссылка существует."
+					} else {
+						message = fmt.Sprintf("Теперь %s%s ведёт на %s. Прежние адреса сохранены в истории.", url, shortURL, newURL)
+					}
+
+					userStates.Delete(chatID)
+					msg = tgbotapi.NewMessage(chatID, message)
+					msg.DisableWebPagePreview = true
+					msg.ReplyMarkup = keyboard
+
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_rule_") {
+					message, done := handleRuleInput(db, chatID, strings.TrimPrefix(state.(string), "awaiting_rule_"), update.Message.Text)
+					msg = tgbotapi.NewMessage(chatID, message)
+					msg.DisableWebPagePreview = true
+					if done {
+						msg.ReplyMarkup = keyboard
+						userStates.Delete(chatID)
+					}
+
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_country_rule_") {
+					message, done := handleCountryRuleInput(db, chatID, strings.TrimPrefix(state.(string), "awaiting_country_rule_"), update.Message.Text)
+					msg = tgbotapi.NewMessage(chatID, message)
+					msg.DisableWebPagePreview = true
+					if done {
+						msg.ReplyMarkup = keyboard
+						userStates.Delete(chatID)
+					}
+
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_variant_") {
+					message, done := handleVariantInput(db, chatID, strings.TrimPrefix(state.(string), "awaiting_variant_"), update.Message.Text)
+					msg = tgbotapi.NewMessage(chatID, message)
+					msg.DisableWebPagePreview = true
+					if done {
+						msg.ReplyMarkup = keyboard
+						userStates.Delete(chatID)
+					}
+
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_activation_") {
+					shortURL := strings.TrimPrefix(state.(string), "awaiting_activation_")
+					message, done := handleLinkActivation(db, chatID, shortURL, update.Message.Text)
+					msg = tgbotapi.NewMessage(chatID, message)
+					if done {
+						msg.ReplyMarkup = keyboard
+						userStates.Delete(chatID)
 					}
 
+				} else if ok && strings.HasPrefix(state.(string), "awaiting_expiry_") {
 					shortURL := strings.TrimPrefix(state.(string), "awaiting_expiry_")
-					newExpiry, err := time.Parse("02-01-2006", update.Message.Text)
+					newExpiry, err := shortener.ParseDateTime(update.Message.Text)
 					if err != nil {
-						msg := tgbotapi.NewMessage(chatID, "Неверный формат даты. Используйте формат: DD-MM-YYYY.")
+						msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Неверный формат даты. Используйте формат: %s.", dateInputFormat))
 						bot.Send(msg)
 						break
 					}
 
-					differenceInDays := int(newExpiry.Sub(time.Now()).Hours() / 24)
-					if newExpiry.Before(time.Now()) || differenceInDays > threasholdDays {
-						msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Нельзя установить прошедшую дату, и срок жизни не может превышать %d дней. Введите заново", threasholdDays))
+					if err := links.CheckExpiry(newExpiry); err != nil {
+						msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Нельзя установить прошедшую дату, и срок жизни не может превышать %d дней. Введите заново", links.MaxLifetimeDays()))
+						bot.Send(msg)
+						break
+					}
+
+					link, err := db.GetUserLink(chatID, shortURL)
+					if err == nil && !link.ActivatesAt.IsZero() && shortener.CheckActivation(link.ActivatesAt, newExpiry) != nil {
+						msg := tgbotapi.NewMessage(chatID, "Ссылка должна истекать позже времени запуска. Введите более позднюю дату или сначала измените время запуска.")
 						bot.Send(msg)
 						break
 					}
 
 					var message string
 
-					err = saving.UpdateLinkExpiry(db.Db, chatID, shortURL, newExpiry)
+					err = db.UpdateLinkExpiry(chatID, shortURL, newExpiry)
 					if err != nil {
 						message = "Не удалось обновить срок хранения. Убедитесь, что ссылка существует."
 					} else {
@@ -324,4 +614,24 @@ func StartBot(url string, db *saving.DB, token string) {
 			}
 		}
 	}
+
+	workers := newDispatcher("user", cfg.Telegram.Workers, handle)
+	defer workers.stop()
+
+	for {
+		var update tgbotapi.Update
+		select {
+		case <-ctx.Done():
+			return nil
+		case update = <-updates:
+		}
+
+		metrics.BotUpdate("user", updateCommand(update))
+		workers.dispatch(ctx, update)
+	}
+}
+
+// formatTime shows t in Moscow time, which the bot uses for every date.
+func formatTime(t time.Time) string {
+	return t.In(shortener.DefaultLocation).Format("02.01.2006, 15:04")
 }
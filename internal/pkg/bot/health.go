@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"2links/internal/pkg/health"
+	"fmt"
+	"net/http"
+	"path"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	UserPoller  = "telegram_user"
+	AdminPoller = "telegram_admin"
+)
+
+type pollerClient struct {
+	name string
+	next tgbotapi.HTTPClient
+}
+
+func (c pollerClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.next.Do(req)
+	if path.Base(req.URL.Path) != "getUpdates" {
+		return resp, err
+	}
+
+	switch {
+	case err != nil:
+		health.ReportPoll(c.name, err)
+	case resp.StatusCode >= http.StatusBadRequest:
+		health.ReportPoll(c.name, fmt.Errorf("Telegram responded %s", resp.Status))
+	default:
+		health.ReportPoll(c.name, nil)
+	}
+
+	return resp, err
+}
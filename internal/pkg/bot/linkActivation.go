@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+func handleLinkActivation(db saving.Store, chatID int64, shortURL string, text string) (string, bool) {
+	link, err := db.GetUserLink(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		return "Не удалось найти ссылку. Убедитесь, что она существует.", true
+	}
+
+	var activatesAt time.Time
+	if strings.TrimSpace(text) != "0" {
+		activatesAt, err = shortener.ParseDateTime(text)
+		if err != nil {
+			return fmt.Sprintf("Неверный формат даты. Используйте формат: %s.", dateInputFormat), false
+		}
+
+		if activatesAt.Before(time.Now()) {
+			return "Время запуска уже прошло. Введите будущую дату или 0, чтобы ссылка работала сразу.", false
+		}
+
+		err = shortener.CheckActivation(activatesAt, link.ExpiresAt)
+		if errors.Is(err, shortener.ErrInvalidExpiry) {
+			return "Ссылка истекает раньше времени запуска. Введите более раннюю дату или сначала продлите срок хранения.", false
+		}
+	}
+
+	err = db.SetLinkActivation(chatID, shortURL, activatesAt)
+	if err != nil {
+		log.Printf("Error setting link activation: %v", err)
+		return "Не удалось изменить время запуска. Убедитесь, что ссылка существует.", true
+	}
+
+	if activatesAt.IsZero() {
+		return "Ссылка работает без отложенного запуска.", true
+	}
+
+	return fmt.Sprintf("Ссылка начнёт работать %s (МСК).", formatTime(activatesAt)), true
+}
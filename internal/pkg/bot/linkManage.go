@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func handleManageMenu(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, url string, shortURL string) {
+	link, err := db.GetUserLink(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ссылку. Убедитесь, что она существует."))
+		return
+	}
+
+	action := func(title, command string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(title, fmt.Sprintf("%s:%s", command, link.ShortURL))
+	}
+
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(action("Статистика", "stats"), action("История", "history"), action("Изменить срок", "update")),
+		tgbotapi.NewInlineKeyboardRow(action("Адрес", "retarget"), action("Устройства", "rules"), action("Варианты", "variants")),
+		tgbotapi.NewInlineKeyboardRow(action("Пароль", "password"), action("Лимит", "budget"), action("Запуск", "activate")),
+		tgbotapi.NewInlineKeyboardRow(action("Редирект", "redirect"), action("Параметры", "passthrough"), action("Удалить", "delete")),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Ссылка %s%s ведёт на %s. Что с ней сделать?", url, link.ShortURL, link.OriginalURL))
+	msg.ReplyMarkup = inlineKeyboard
+	msg.DisableWebPagePreview = true
+	bot.Send(msg)
+}
+
+func handleDeletePrompt(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, shortURL string) {
+	link, err := db.GetUserLink(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ссылку. Убедитесь, что она существует."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Удалить ссылку %s, которая ведёт на %s? Это действие нельзя отменить.", link.ShortURL, link.OriginalURL))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Да, удалить", "confirmdelete:"+link.ShortURL),
+		tgbotapi.NewInlineKeyboardButtonData("Отмена", "back"),
+	))
+	msg.DisableWebPagePreview = true
+	bot.Send(msg)
+}
+
+func handleConfirmDelete(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, shortURL string, keyboard tgbotapi.ReplyKeyboardMarkup) {
+	message := "Ссылка удалена"
+	err := db.DeleteUserLink(chatID, shortURL)
+	if errors.Is(err, saving.ErrLinkNotFound) {
+		message = "Не удалось найти ссылку. Возможно, она уже удалена."
+	} else if err != nil {
+		log.Printf("Error deleting link: %v", err)
+		message = "Не удалось удалить ссылку. Попробуйте позже."
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ReplyMarkup = keyboard
+	bot.Send(msg)
+}
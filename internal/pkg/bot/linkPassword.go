@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"errors"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func handleLinkPassword(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, shortURL string, message *tgbotapi.Message) (string, bool) {
+	switch message.Text {
+	case buttonSkip:
+		return "Пароль не изменён.", true
+
+	case buttonNoPass:
+		err := db.SetLinkPassword(chatID, shortURL, "")
+		if err != nil {
+			log.Printf("Error removing link password: %v", err)
+			return "Не удалось снять пароль. Убедитесь, что ссылка существует.", true
+		}
+
+		return "Пароль снят, ссылка открывается без него.", true
+	}
+
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(chatID, message.MessageID)); err != nil {
+		log.Printf("Error deleting password message: %v", err)
+	}
+
+	hash, err := shortener.HashLinkPassword(message.Text)
+	if errors.Is(err, shortener.ErrPasswordShort) {
+		return "Пароль слишком короткий: нужно не меньше 4 символов. Введите другой", false
+	} else if err != nil {
+		log.Printf("Error hashing link password: %v", err)
+		return "Не удалось установить пароль. Попробуйте позже.", true
+	}
+
+	err = db.SetLinkPassword(chatID, shortURL, hash)
+	if err != nil {
+		log.Printf("Error setting link password: %v", err)
+		return "Не удалось установить пароль. Убедитесь, что ссылка существует.", true
+	}
+
+	return "Пароль установлен. Сообщение с ним я удалил из чата.", true
+}
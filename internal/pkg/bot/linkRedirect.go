@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func handleRedirectMenu(bot *tgbotapi.BotAPI, chatID int64, shortURL string) {
+	option := func(status int) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(redirectTitle(status), fmt.Sprintf("setredirect:%s:%d", shortURL, status))
+	}
+
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(option(http.StatusMovedPermanently), option(http.StatusPermanentRedirect)),
+		tgbotapi.NewInlineKeyboardRow(option(http.StatusFound), option(http.StatusTemporaryRedirect)),
+		tgbotapi.NewInlineKeyboardRow(option(0)),
+	)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Выберите тип перенаправления для ссылки %s.\n\n"+
+			"301/308 — постоянные: браузеры и поисковики запоминают адрес, повторные переходы могут не попасть в статистику.\n"+
+			"302/307 — временные: каждый переход учитывается. 307 и 308 сохраняют метод запроса.",
+		shortURL,
+	))
+	msg.ReplyMarkup = inlineKeyboard
+	bot.Send(msg)
+}
+
+func handleSetRedirect(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, data string) {
+	sep := strings.LastIndex(data, ":")
+	if sep < 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Неизвестный тип перенаправления."))
+		return
+	}
+
+	shortURL := data[:sep]
+	status, err := strconv.Atoi(data[sep+1:])
+	if err != nil || status != 0 && !shortener.ValidRedirectStatus(status) {
+		bot.Send(tgbotapi.NewMessage(chatID, "Неизвестный тип перенаправления."))
+		return
+	}
+
+	err = db.SetRedirectStatus(chatID, shortURL, status)
+	if err != nil {
+		log.Printf("Error setting redirect status: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить перенаправление. Убедитесь, что ссылка существует."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ссылка %s: %s.", shortURL, redirectTitle(status))))
+}
+
+func redirectTitle(status int) string {
+	switch status {
+	case http.StatusMovedPermanently:
+		return "301 постоянный"
+	case http.StatusFound:
+		return "302 временный"
+	case http.StatusTemporaryRedirect:
+		return "307 временный"
+	case http.StatusPermanentRedirect:
+		return "308 постоянный"
+	}
+
+	return "по умолчанию"
+}
+
+func togglePassthrough(db saving.Store, chatID int64, shortURL string) string {
+	link, err := db.GetUserLink(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		return "Не удалось найти ссылку. Убедитесь, что она существует."
+	}
+
+	err = db.SetPassthrough(chatID, shortURL, !link.Passthrough)
+	if err != nil {
+		log.Printf("Error setting passthrough: %v", err)
+		return "Не удалось изменить настройку. Попробуйте позже."
+	}
+
+	if link.Passthrough {
+		return fmt.Sprintf("Ссылка %s больше не передаёт параметры и путь.", shortURL)
+	}
+
+	return fmt.Sprintf(
+		"Теперь %s передаёт параметры и путь: переход по %s/docs?utm_source=tg откроет исходный адрес с добавленными /docs и utm_source=tg. "+
+			"Если параметр уже есть в исходном адресе, сохраняется его значение.",
+		shortURL, shortURL,
+	)
+}
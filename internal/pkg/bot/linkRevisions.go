@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"fmt"
+	"log"
+)
+
+func linkHistory(db saving.Store, chatID int64, shortURL string) string {
+	revisions, err := db.GetLinkRevisions(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link revisions: %v", err)
+		return "Не удалось загрузить историю. Убедитесь, что ссылка существует."
+	}
+
+	message := fmt.Sprintf("История адресов ссылки %s:\n\n", shortURL)
+	for i, revision := range revisions {
+		status := fmt.Sprintf("с %s", formatTime(revision.CreatedAt))
+		if i == 0 {
+			status += " (текущий)"
+		}
+
+		message += fmt.Sprintf("%s\n%s, переходов: %d\n\n", revision.OriginalURL, status, revision.Clicks)
+	}
+
+	return message
+}
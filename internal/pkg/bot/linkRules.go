@@ -0,0 +1,138 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var deviceTitles = map[string]string{
+	shortener.DeviceIOS:     "iPhone/iPad",
+	shortener.DeviceAndroid: "Android",
+	shortener.DeviceDesktop: "Компьютер",
+}
+
+func handleRulesMenu(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, shortURL string) {
+	link, err := db.GetUserLink(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ссылку. Убедитесь, что она существует."))
+		return
+	}
+
+	rules, err := db.GetLinkRules(link.ID)
+	if err != nil {
+		log.Printf("Error fetching link rules: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить правила. Попробуйте позже."))
+		return
+	}
+
+	message := fmt.Sprintf("Куда вести посетителей ссылки %s с разных устройств:\n\n", shortURL)
+	targets := make(map[string]string)
+	var countries []saving.LinkRule
+	for _, rule := range rules {
+		switch rule.Kind {
+		case shortener.RuleDevice:
+			targets[rule.Match] = rule.TargetURL
+		case shortener.RuleCountry:
+			countries = append(countries, rule)
+		}
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, device := range []string{shortener.DeviceIOS, shortener.DeviceAndroid, shortener.DeviceDesktop} {
+		target, ok := targets[device]
+		if !ok {
+			target = "основной адрес"
+		}
+
+		message += fmt.Sprintf("%s: %s\n", deviceTitles[device], target)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(deviceTitles[device], fmt.Sprintf("setrule:%s:%s", shortURL, device)))
+	}
+
+	if len(countries) > 0 {
+		message += "\nЕсли устройство не задано, по стране посетителя:\n"
+		for _, rule := range countries {
+			message += fmt.Sprintf("%s: %s\n", rule.Match, rule.TargetURL)
+		}
+	}
+
+	message += fmt.Sprintf("\nОстальные: %s\n\nВыберите устройство или страну, чтобы изменить адрес.", link.OriginalURL)
+
+	countryRow := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Страна", fmt.Sprintf("setcountry:%s", shortURL)))
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row, countryRow)
+	msg.DisableWebPagePreview = true
+	bot.Send(msg)
+}
+
+func handleRuleInput(db saving.Store, chatID int64, target string, text string) (string, bool) {
+	sep := strings.LastIndex(target, ":")
+	if sep < 0 {
+		return "Неизвестное устройство.", true
+	}
+
+	shortURL, device := target[:sep], target[sep+1:]
+	if strings.TrimSpace(text) == "0" {
+		err := db.DeleteLinkRule(chatID, shortURL, shortener.RuleDevice, device)
+		if errors.Is(err, saving.ErrRuleNotFound) {
+			return "Для этого устройства и так используется основной адрес.", true
+		} else if err != nil {
+			log.Printf("Error deleting link rule: %v", err)
+			return "Не удалось удалить правило. Попробуйте позже.", true
+		}
+
+		return fmt.Sprintf("%s теперь открывает основной адрес.", deviceTitles[device]), true
+	}
+
+	rule := shortener.NormalizeRule(saving.LinkRule{Kind: shortener.RuleDevice, Match: device, TargetURL: text})
+	if err := shortener.ValidateRule(rule); err != nil {
+		return "Эта ссылка не действительна, введите другую или 0, чтобы удалить правило", false
+	}
+
+	err := db.SetLinkRule(chatID, shortURL, rule)
+	if err != nil {
+		log.Printf("Error setting link rule: %v", err)
+		return "Не удалось сохранить правило. Убедитесь, что ссылка существует.", true
+	}
+
+	return fmt.Sprintf("%s теперь открывает %s.", deviceTitles[device], rule.TargetURL), true
+}
+
+func handleCountryRuleInput(db saving.Store, chatID int64, shortURL string, text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return "Введите код страны и адрес через пробел, например RU https://example.ru, или RU 0, чтобы удалить правило", false
+	}
+
+	rule := shortener.NormalizeRule(saving.LinkRule{Kind: shortener.RuleCountry, Match: fields[0], TargetURL: fields[1]})
+	if rule.TargetURL == "0" {
+		err := db.DeleteLinkRule(chatID, shortURL, shortener.RuleCountry, rule.Match)
+		if errors.Is(err, saving.ErrRuleNotFound) {
+			return fmt.Sprintf("Для страны %s и так используется основной адрес.", rule.Match), true
+		} else if err != nil {
+			log.Printf("Error deleting link rule: %v", err)
+			return "Не удалось удалить правило. Попробуйте позже.", true
+		}
+
+		return fmt.Sprintf("Посетители из %s теперь попадают на основной адрес.", rule.Match), true
+	}
+
+	if err := shortener.ValidateRule(rule); err != nil {
+		return "Нужен двухбуквенный код страны и действительная ссылка, например RU https://example.ru. Попробуйте ещё раз", false
+	}
+
+	err := db.SetLinkRule(chatID, shortURL, rule)
+	if err != nil {
+		log.Printf("Error setting link rule: %v", err)
+		return "Не удалось сохранить правило. Убедитесь, что ссылка существует.", true
+	}
+
+	return fmt.Sprintf("Посетители из %s теперь попадают на %s.", rule.Match, rule.TargetURL), true
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const statsCitiesLimit = 10
+
+func linkStats(db saving.Store, chatID int64, shortURL string) string {
+	if _, err := db.GetUserLink(chatID, shortURL); err != nil {
+		log.Printf("Error fetching link: %v", err)
+		return "Не удалось найти ссылку. Убедитесь, что она существует."
+	}
+
+	geo, err := db.GetGeoClicks(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching clicks by location: %v", err)
+		return "Не удалось загрузить статистику. Попробуйте позже."
+	}
+
+	if len(geo) == 0 {
+		return fmt.Sprintf("По ссылке %s ещё не было переходов.", shortURL)
+	}
+
+	variants, err := db.GetVariantClicks(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching clicks by variant: %v", err)
+		return "Не удалось загрузить статистику. Попробуйте позже."
+	}
+
+	total := 0
+	byCountry := make(map[string]int)
+	var countries []string
+	var cities []saving.GeoClicks
+	for _, entry := range geo {
+		total += entry.Clicks
+		if _, ok := byCountry[entry.Country]; !ok {
+			countries = append(countries, entry.Country)
+		}
+		byCountry[entry.Country] += entry.Clicks
+
+		if entry.City != "" {
+			cities = append(cities, entry)
+		}
+	}
+
+	sort.SliceStable(countries, func(i, j int) bool {
+		return byCountry[countries[i]] > byCountry[countries[j]]
+	})
+
+	message := fmt.Sprintf("Статистика ссылки %s, всего переходов: %d\n\nПо странам:\n", shortURL, total)
+	for _, country := range countries {
+		message += fmt.Sprintf("%s: %d\n", countryTitle(country), byCountry[country])
+	}
+
+	if len(cities) > 0 {
+		message += "\nПо городам:\n"
+		for i, entry := range cities {
+			if i == statsCitiesLimit {
+				break
+			}
+
+			message += fmt.Sprintf("%s, %s: %d\n", entry.City, entry.Country, entry.Clicks)
+		}
+	}
+
+	variantTotal := 0
+	for _, variant := range variants {
+		variantTotal += variant.Clicks
+	}
+
+	if len(variants) > 0 {
+		message += "\nПо вариантам:\n"
+		for i, variant := range variants {
+			share := 0.0
+			if variantTotal > 0 {
+				share = float64(variant.Clicks) * 100 / float64(variantTotal)
+			}
+
+			message += fmt.Sprintf("%d. %s (вес %d): %d, %.1f%%\n", i+1, variant.TargetURL, variant.Weight, variant.Clicks, share)
+		}
+	}
+
+	return message
+}
+
+func countryTitle(country string) string {
+	if country == "" {
+		return "не определена"
+	}
+
+	return country
+}
@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func handleVariantsMenu(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, shortURL string) {
+	variants, err := db.GetVariantClicks(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link variants: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Не удалось найти ссылку. Убедитесь, что она существует."))
+		return
+	}
+
+	var message string
+	if len(variants) == 0 {
+		message = fmt.Sprintf("У ссылки %s нет вариантов, все посетители попадают на основной адрес.\n\n", shortURL)
+	} else {
+		message = fmt.Sprintf("Посетители ссылки %s распределяются между вариантами по весу, каждый остаётся на своём варианте. Основной адрес не используется, пока есть варианты.\n\n", shortURL)
+	}
+
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup()
+	for i, variant := range variants {
+		message += fmt.Sprintf("%d. %s\nвес %d, переходов: %d\n\n", i+1, variant.TargetURL, variant.Weight, variant.Clicks)
+		inlineKeyboard.InlineKeyboard = append(
+			inlineKeyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("Удалить вариант %d", i+1),
+				fmt.Sprintf("delvariant:%s:%d", shortURL, variant.ID),
+			)),
+		)
+	}
+
+	if len(variants) < shortener.MaxVariants {
+		inlineKeyboard.InlineKeyboard = append(
+			inlineKeyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Добавить вариант", fmt.Sprintf("addvariant:%s", shortURL))),
+		)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ReplyMarkup = inlineKeyboard
+	msg.DisableWebPagePreview = true
+	bot.Send(msg)
+}
+
+func handleVariantInput(db saving.Store, chatID int64, shortURL string, text string) (string, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "Введите адрес и, через пробел, вес от 1 до 100, например https://example.com/b 50", false
+	}
+
+	variant := saving.LinkVariant{TargetURL: fields[0], Weight: 1}
+	if len(fields) == 2 {
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return "Вес должен быть числом от 1 до 100. Попробуйте ещё раз", false
+		}
+		variant.Weight = weight
+	}
+
+	if err := shortener.ValidateVariant(variant); err != nil {
+		return "Нужна действительная ссылка и вес от 1 до 100, например https://example.com/b 50. Попробуйте ещё раз", false
+	}
+
+	link, err := db.GetUserLink(chatID, shortURL)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		return "Не удалось найти ссылку. Убедитесь, что она существует.", true
+	}
+
+	variants, err := db.GetLinkVariants(link.ID)
+	if err != nil {
+		log.Printf("Error fetching link variants: %v", err)
+		return "Не удалось загрузить варианты. Попробуйте позже.", true
+	}
+
+	if len(variants) >= shortener.MaxVariants {
+		return fmt.Sprintf("У ссылки уже %d вариантов, удалите один, чтобы добавить новый.", shortener.MaxVariants), true
+	}
+
+	err = db.AddLinkVariant(chatID, shortURL, variant)
+	if err != nil {
+		log.Printf("Error adding link variant: %v", err)
+		return "Не удалось сохранить вариант. Убедитесь, что ссылка существует.", true
+	}
+
+	return fmt.Sprintf("Вариант %s с весом %d добавлен к ссылке %s.", variant.TargetURL, variant.Weight, shortURL), true
+}
+
+func deleteVariant(db saving.Store, chatID int64, target string) string {
+	sep := strings.LastIndex(target, ":")
+	if sep < 0 {
+		return "Вариант не найден."
+	}
+
+	shortURL := target[:sep]
+	variantID, err := strconv.Atoi(target[sep+1:])
+	if err != nil {
+		return "Вариант не найден."
+	}
+
+	err = db.DeleteLinkVariant(chatID, shortURL, variantID)
+	if errors.Is(err, saving.ErrVariantNotFound) {
+		return "Вариант не найден."
+	} else if err != nil {
+		log.Printf("Error deleting link variant: %v", err)
+		return "Не удалось удалить вариант. Попробуйте позже."
+	}
+
+	return fmt.Sprintf("Вариант удалён из ссылки %s.", shortURL)
+}
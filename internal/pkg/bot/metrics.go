@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	messageCommands = map[string]string{
+		"/start":        "start",
+		"/help":         "help",
+		buttonHelp:      "help",
+		"/apikeys":      "apikeys",
+		"/utm":          "utm",
+		"/feedback":     "feedback",
+		buttonFeedback:  "feedback",
+		buttonShorten:   "shorten",
+		buttonMyLinks:   "my_links",
+		buttonComplaint: "complaint",
+
+		buttonSuspiciousLinks: "suspicious_links",
+		buttonLastReviews:     "last_reviews",
+		buttonMiddleGrade:     "middle_grade",
+		buttonStatistics:      "statistics",
+	}
+
+	callbackCommands = map[string]bool{
+		"activate": true, "addvariant": true, "back": true, "budget": true, "confirmdelete": true, "delete": true, "deleteutm": true,
+		"delvariant": true, "history": true, "manage": true, "newkey": true, "passthrough": true, "password": true,
+		"redirect": true, "retarget": true, "revokekey": true, "rules": true, "setcountry": true, "setredirect": true,
+		"setrule": true, "stats": true, "update": true, "variants": true,
+	}
+)
+
+func updateCommand(update tgbotapi.Update) string {
+	switch {
+	case update.CallbackQuery != nil:
+		prefix, _, _ := strings.Cut(update.CallbackQuery.Data, ":")
+		if callbackCommands[prefix] {
+			return "callback_" + prefix
+		}
+		return "callback_other"
+
+	case update.PollAnswer != nil:
+		return "poll_answer"
+
+	case update.Message != nil:
+		if command, ok := messageCommands[update.Message.Text]; ok {
+			return command
+		}
+
+		if strings.HasPrefix(update.Message.Text, "/delete_") {
+			return "delete_link"
+		}
+
+		if update.Message.IsCommand() {
+			return "unknown_command"
+		}
+		return "message"
+	}
+
+	return "other"
+}
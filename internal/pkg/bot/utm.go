@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	buttonConfirm = "Подтвердить"
+	presetPrefix  = "Шаблон: "
+)
+
+var confirmKeyboard = tgbotapi.NewReplyKeyboard(
+	tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonConfirm), tgbotapi.NewKeyboardButton(buttonSkip)),
+)
+
+func utmKeyboard(db saving.Store, chatID int64) tgbotapi.ReplyKeyboardMarkup {
+	presets, err := db.ListUTMPresets(chatID)
+	if err != nil {
+		log.Printf("Error fetching UTM presets: %v", err)
+	}
+
+	var rows [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(presets); i += 2 {
+		row := tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(presetPrefix + presets[i].Name))
+		if i+1 < len(presets) {
+			row = append(row, tgbotapi.NewKeyboardButton(presetPrefix+presets[i+1].Name))
+		}
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(buttonSkip)))
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+func buildUTMPreview(db saving.Store, chatID int64, longLink string, text string) (string, string) {
+	var utm saving.UTM
+	if name, ok := strings.CutPrefix(text, presetPrefix); ok {
+		preset, err := db.GetUTMPreset(chatID, name)
+		if errors.Is(err, saving.ErrUTMPresetNotFound) {
+			return "", "Такого шаблона нет. Выберите другой или введите метки"
+		} else if err != nil {
+			log.Printf("Error fetching UTM preset: %v", err)
+			return "", "Не удалось загрузить шаблон. Попробуйте позже"
+		}
+		utm = preset.UTM
+	} else {
+		parsed, presetName, err := shortener.ParseUTM(text)
+		if err != nil {
+			return "", "Не удалось разобрать метки: нужен как минимум source, например source=tg medium=post campaign=fall. Попробуйте ещё раз"
+		}
+		utm = parsed
+
+		if presetName != "" {
+			err = db.SaveUTMPreset(chatID, saving.UTMPreset{Name: presetName, UTM: utm})
+			if err != nil {
+				log.Printf("Error saving UTM preset: %v", err)
+			}
+		}
+	}
+
+	preview := shortener.ApplyUTM(longLink, utm)
+	return preview, fmt.Sprintf("Итоговый адрес:\n%s\n\nНажмите «Подтвердить», чтобы сократить его, или отправьте другие метки", preview)
+}
+
+func handleUTMPresets(bot *tgbotapi.BotAPI, db saving.Store, chatID int64) {
+	presets, err := db.ListUTMPresets(chatID)
+	if err != nil {
+		log.Printf("Error fetching UTM presets: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Ошибка при получении шаблонов. Попробуйте позже."))
+		return
+	}
+
+	if len(presets) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "У вас пока нет шаблонов UTM. Чтобы сохранить шаблон, добавьте preset=имя к меткам при сокращении ссылки."))
+		return
+	}
+
+	message := "Ваши шаблоны UTM:\n"
+	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup()
+	for _, preset := range presets {
+		message += fmt.Sprintf("\n%s: %s\n", preset.Name, strings.TrimPrefix(shortener.ApplyUTM("", preset.UTM), "?"))
+		inlineKeyboard.InlineKeyboard = append(
+			inlineKeyboard.InlineKeyboard,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("Удалить %s", preset.Name),
+				fmt.Sprintf("deleteutm:%s", preset.Name),
+			)),
+		)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ReplyMarkup = inlineKeyboard
+	bot.Send(msg)
+}
+
+func handleDeleteUTMPreset(bot *tgbotapi.BotAPI, db saving.Store, chatID int64, name string) {
+	err := db.DeleteUTMPreset(chatID, name)
+	if err != nil {
+		log.Printf("Error deleting UTM preset: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "Шаблон не найден."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Шаблон %s удалён.", name)))
+}
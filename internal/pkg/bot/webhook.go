@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"2links/internal/pkg/health"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	webhookSecretHeader    = "X-Telegram-Bot-Api-Secret-Token"
+	webhookCheckInterval   = time.Minute
+	webhookDeliveryTimeout = 10 * time.Second
+	webhookBufferSize      = 100
+	maxUpdateSize          = 1 << 20
+)
+
+// Webhook receives the updates Telegram posts to a secret path of the public
+// server. The path is derived from the bot token, so each bot gets its own.
+type Webhook struct {
+	url     string
+	path    string
+	secret  string
+	updates chan tgbotapi.Update
+}
+
+func NewWebhook(baseURL, token, secret string) *Webhook {
+	sum := sha256.Sum256([]byte(token))
+	path := "/telegram/" + hex.EncodeToString(sum[:16])
+
+	return &Webhook{
+		url:     strings.TrimSuffix(baseURL, "/") + path,
+		path:    path,
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, webhookBufferSize),
+	}
+}
+
+func (w *Webhook) Path() string {
+	return w.path
+}
+
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(w.secret)) != 1 {
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxUpdateSize)).Decode(&update); err != nil {
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	timer := time.NewTimer(webhookDeliveryTimeout)
+	defer timer.Stop()
+
+	select {
+	case w.updates <- update:
+	case <-timer.C:
+		// Telegram redelivers the update later.
+		http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+func (w *Webhook) register(bot *tgbotapi.BotAPI) error {
+	// WebhookConfig of this library version has no secret_token field, and
+	// debug logging would print the request parameters with the secret.
+	debug := bot.Debug
+	bot.Debug = false
+	defer func() { bot.Debug = debug }()
+
+	_, err := bot.MakeRequest("setWebhook", tgbotapi.Params{"url": w.url, "secret_token": w.secret})
+	return err
+}
+
+// watch stands in for the getUpdates health reports while the webhook is
+// used. Delivery errors are only logged: failing readiness because of them
+// would take the instance out of rotation and cause more of them.
+func (w *Webhook) watch(ctx context.Context, bot *tgbotapi.BotAPI, poller string) {
+	ticker := time.NewTicker(webhookCheckInterval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for {
+		info, err := bot.GetWebhookInfo()
+		if err == nil && info.URL != w.url {
+			err = errors.New("Webhook is not registered")
+		}
+
+		if err == nil && int64(info.LastErrorDate) >= since.Unix() {
+			log.Printf("Telegram failed to deliver an update to %s: %s", bot.Self.UserName, info.LastErrorMessage)
+		}
+
+		health.ReportPoll(poller, err)
+		since = time.Now()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// receiveUpdates registers the webhook if there is one and falls back to long
+// polling when there is none or Telegram rejects it. The returned function
+// stops receiving updates.
+func receiveUpdates(ctx context.Context, bot *tgbotapi.BotAPI, poller string, webhook *Webhook) (tgbotapi.UpdatesChannel, func()) {
+	if webhook != nil {
+		err := webhook.register(bot)
+		if err == nil {
+			log.Printf("Receiving updates for %s through the webhook", bot.Self.UserName)
+			go webhook.watch(ctx, bot, poller)
+			return webhook.updates, func() { deleteWebhook(bot) }
+		}
+
+		log.Printf("Failed to register webhook for %s, falling back to long polling: %v", bot.Self.UserName, err)
+	}
+
+	// getUpdates is rejected while a webhook from an earlier run is still set.
+	deleteWebhook(bot)
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	return bot.GetUpdatesChan(u), bot.StopReceivingUpdates
+}
+
+func deleteWebhook(bot *tgbotapi.BotAPI) {
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("Failed to delete webhook for %s: %v", bot.Self.UserName, err)
+	}
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postUpdate(w *Webhook, secret, body string) int {
+	r := httptest.NewRequest(http.MethodPost, w.Path(), strings.NewReader(body))
+	if secret != "" {
+		r.Header.Set(webhookSecretHeader, secret)
+	}
+
+	rw := httptest.NewRecorder()
+	w.ServeHTTP(rw, r)
+	return rw.Code
+}
+
+func TestWebhookAuth(t *testing.T) {
+	w := NewWebhook("https://2l.test/", "123:token", "s3cret")
+
+	for _, secret := range []string{"", "wrong", "s3cret2", "S3CRET"} {
+		if code := postUpdate(w, secret, `{"update_id": 1}`); code != http.StatusForbidden {
+			t.Errorf("update with secret %q = %d, want 403", secret, code)
+		}
+	}
+
+	if len(w.updates) != 0 {
+		t.Fatalf("%d rejected updates were delivered", len(w.updates))
+	}
+
+	if code := postUpdate(w, "s3cret", `{"update_id": `); code != http.StatusBadRequest {
+		t.Errorf("malformed update = %d, want 400", code)
+	}
+
+	if code := postUpdate(w, "s3cret", `{"update_id": 7}`); code != http.StatusOK {
+		t.Fatalf("update with the secret = %d, want 200", code)
+	}
+
+	if update := <-w.updates; update.UpdateID != 7 {
+		t.Errorf("delivered update %d, want 7", update.UpdateID)
+	}
+}
+
+func TestWebhookPath(t *testing.T) {
+	user := NewWebhook("https://2l.test", "123:token", "s3cret")
+	admin := NewWebhook("https://2l.test", "456:token", "s3cret")
+
+	if !strings.HasPrefix(user.Path(), "/telegram/") || user.Path() == admin.Path() {
+		t.Errorf("paths %q and %q must be distinct paths under /telegram/", user.Path(), admin.Path())
+	}
+
+	if strings.Contains(user.Path(), "token") || user.url != "https://2l.test"+user.Path() {
+		t.Errorf("path %q, url %q", user.Path(), user.url)
+	}
+
+	if again := NewWebhook("https://2l.test/", "123:token", "other"); again.Path() != user.Path() {
+		t.Errorf("path changed between runs: %q, was %q", again.Path(), user.Path())
+	}
+}
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const workerQueueSize = 64
+
+// dispatcher handles updates on a fixed number of workers. All updates of a
+// chat go to the same worker, so they are processed in the order received
+// while other chats do not wait for them.
+type dispatcher struct {
+	bot    string
+	handle func(tgbotapi.Update)
+	queues []chan tgbotapi.Update
+	wg     sync.WaitGroup
+}
+
+func newDispatcher(bot string, workers int, handle func(tgbotapi.Update)) *dispatcher {
+	d := &dispatcher{
+		bot:    bot,
+		handle: handle,
+		queues: make([]chan tgbotapi.Update, max(workers, 1)),
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan tgbotapi.Update, workerQueueSize)
+		d.wg.Add(1)
+		go d.run(d.queues[i])
+	}
+
+	return d
+}
+
+// dispatch blocks while the chat's worker is busy and its queue is full.
+func (d *dispatcher) dispatch(ctx context.Context, update tgbotapi.Update) {
+	queue := d.queues[uint64(updateChatID(update))%uint64(len(d.queues))]
+	select {
+	case queue <- update:
+	case <-ctx.Done():
+	}
+}
+
+// stop waits for the queued updates to be handled.
+func (d *dispatcher) stop() {
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	d.wg.Wait()
+}
+
+func (d *dispatcher) run(queue <-chan tgbotapi.Update) {
+	defer d.wg.Done()
+	for update := range queue {
+		d.handleSafely(update)
+	}
+}
+
+func (d *dispatcher) handleSafely(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic while handling %s bot update %d: %v\n%s", d.bot, update.UpdateID, r, debug.Stack())
+		}
+	}()
+
+	d.handle(update)
+}
+
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.PollAnswer != nil:
+		// Poll answers come from the user's private chat with the bot.
+		return update.PollAnswer.User.ID
+	}
+
+	return 0
+}
@@ -0,0 +1,210 @@
+package clickqueue
+
+import (
+	"2links/internal/pkg/saving"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSize          = 10000
+	DefaultBatchSize     = 200
+	DefaultFlushInterval = time.Second
+
+	enqueueTimeout  = 20 * time.Millisecond
+	dropLogEvery    = 1000
+	flushAttempts   = 3
+	flushRetryDelay = 250 * time.Millisecond
+)
+
+type Queue struct {
+	store         saving.Store
+	clicks        chan saving.Click
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued atomic.Int64
+	flushed  atomic.Int64
+	dropped  atomic.Int64
+	failed   atomic.Int64
+}
+
+type Stats struct {
+	Enqueued int64
+	Flushed  int64
+	Dropped  int64
+	Failed   int64
+	Pending  int
+	Capacity int
+}
+
+func New(store saving.Store, size int, batchSize int, flushInterval time.Duration) *Queue {
+	if size <= 0 {
+		size = DefaultSize
+	}
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+
+	q := &Queue{
+		store:         store,
+		clicks:        make(chan saving.Click, size),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+
+	go q.run()
+	return q
+}
+
+// Enqueue waits briefly for room when the queue is full so short bursts are
+// absorbed, then drops the click rather than stall the redirect.
+func (q *Queue) Enqueue(click saving.Click) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case q.clicks <- click:
+		q.enqueued.Add(1)
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(enqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case q.clicks <- click:
+		q.enqueued.Add(1)
+		return true
+	case <-timer.C:
+		q.drop()
+		return false
+	}
+}
+
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+
+	q.closed = true
+	close(q.clicks)
+	q.mu.Unlock()
+
+	<-q.done
+	log.Printf("Click queue drained: %d flushed, %d dropped, %d failed", q.flushed.Load(), q.dropped.Load(), q.failed.Load())
+}
+
+func (q *Queue) Stats() Stats {
+	return Stats{
+		Enqueued: q.enqueued.Load(),
+		Flushed:  q.flushed.Load(),
+		Dropped:  q.dropped.Load(),
+		Failed:   q.failed.Load(),
+		Pending:  len(q.clicks),
+		Capacity: cap(q.clicks),
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]saving.Click, 0, q.batchSize)
+	for {
+		select {
+		case click, ok := <-q.clicks:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+
+			batch = append(batch, click)
+			if len(batch) >= q.batchSize {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			q.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (q *Queue) flush(batch []saving.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := q.store.SaveClicks(batch)
+	for attempt := 1; err != nil && attempt < flushAttempts; attempt++ {
+		time.Sleep(time.Duration(attempt) * flushRetryDelay)
+		err = q.store.SaveClicks(batch)
+	}
+
+	if err == nil {
+		q.flushed.Add(int64(len(batch)))
+		return
+	}
+
+	if pingErr := q.store.Ping(); pingErr != nil {
+		q.failed.Add(int64(len(batch)))
+		log.Printf("Failed to flush %d click(s): %v", len(batch), err)
+		return
+	}
+
+	// The database is up but rejects the batch, so some clicks in it are bad:
+	// their link was deleted meanwhile or a field does not fit its column.
+	// Saving the batch in halves loses only those clicks.
+	log.Printf("Failed to flush %d click(s), saving them in smaller batches: %v", len(batch), err)
+	q.flushSplit(batch, err)
+}
+
+// flushSplit saves a batch the database rejected in halves, down to the
+// single clicks it rejects.
+func (q *Queue) flushSplit(batch []saving.Click, err error) {
+	if len(batch) == 1 {
+		q.failed.Add(1)
+		log.Printf("Dropped click on link %d: %v", batch[0].LinkID, err)
+		return
+	}
+
+	half := len(batch) / 2
+	for _, part := range [][]saving.Click{batch[:half], batch[half:]} {
+		if err := q.store.SaveClicks(part); err != nil {
+			q.flushSplit(part, err)
+			continue
+		}
+
+		q.flushed.Add(int64(len(part)))
+	}
+}
+
+func (q *Queue) drop() {
+	if n := q.dropped.Add(1); n == 1 || n%dropLogEvery == 0 {
+		log.Printf("Click queue is full, %d click(s) dropped so far", n)
+	}
+}
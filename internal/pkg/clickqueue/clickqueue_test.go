@@ -0,0 +1,50 @@
+package clickqueue
+
+import (
+	"2links/internal/pkg/saving"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFlushKeepsValidClicks(t *testing.T) {
+	db, err := saving.CreateSQLite(filepath.Join(t.TempDir(), "links.db"))
+	if err != nil {
+		t.Fatalf("CreateSQLite: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddUser(1); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+
+	link := saving.NewLink{UserID: 1, ShortURL: "abc", OriginalURL: "https://example.com", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.SaveLink(link); err != nil {
+		t.Fatalf("SaveLink: %v", err)
+	}
+
+	linkID, err := db.FindLink("abc")
+	if err != nil {
+		t.Fatalf("FindLink: %v", err)
+	}
+
+	queue := New(db, 10, 10, time.Hour)
+	for i := range 5 {
+		id := linkID
+		if i == 2 {
+			// The link of this click was deleted while it was queued.
+			id = linkID + 1
+		}
+		queue.Enqueue(saving.Click{LinkID: id})
+	}
+	queue.Close()
+
+	if stats := queue.Stats(); stats.Flushed != 4 || stats.Failed != 1 {
+		t.Errorf("Stats = %+v, want 4 flushed and 1 failed", stats)
+	}
+
+	clicks, err := db.GetClicksByUser(1)
+	if err != nil || clicks["abc"].Clicks != 4 {
+		t.Errorf("GetClicksByUser = %v, %v, want 4 clicks", clicks, err)
+	}
+}
@@ -0,0 +1,404 @@
+package config
+
+import (
+	"2links/internal/pkg/clickqueue"
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPort                  = "8080"
+	defaultMetricsPort           = "9090"
+	defaultAdminPasswordHashFile = "internal/pkg/bot/adminPasswordHash.txt"
+	defaultBotWorkers            = 8
+)
+
+// Telegram only accepts these characters in a webhook secret token.
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+type Config struct {
+	Port           string
+	MetricsPort    string
+	Domain         string
+	GeoIPPath      string
+	TrustedProxies string
+
+	Telegram Telegram
+	Database Database
+	Links    Links
+	Cache    Cache
+	Clicks   Clicks
+}
+
+type Telegram struct {
+	Token                 string
+	AdminToken            string
+	AdminPasswordHashFile string
+	WebhookURL            string
+	WebhookSecret         string
+	Workers               int
+}
+
+type Database struct {
+	Driver          string
+	Postgres        string
+	PostgresDefault string
+	SQLitePath      string
+}
+
+type Links struct {
+	DefaultLifetimeDays int
+	MaxLifetimeDays     int
+	RedirectStatus      int
+	CodeGenerator       string
+	CodeLength          int
+	CodeSalt            string
+}
+
+type Cache struct {
+	Size int
+	TTL  time.Duration
+}
+
+type Clicks struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// option binds one setting to its environment variable. The flag and the
+// config file key are derived from the variable name, e.g. LINK_CACHE_TTL is
+// -link-cache-ttl on the command line and "link-cache-ttl" in the file.
+type option struct {
+	env   string
+	usage string
+	set   func(value string) error
+}
+
+func (o option) key() string {
+	return strings.ToLower(strings.ReplaceAll(o.env, "_", "-"))
+}
+
+// Load builds the configuration from defaults, the JSON file given by -config
+// or CONFIG_FILE, the environment and the command line flags, each overriding
+// the previous one. It returns the arguments left after the flags.
+func Load(args []string) (*Config, []string, error) {
+	cfg := &Config{
+		Port:        defaultPort,
+		MetricsPort: defaultMetricsPort,
+		Telegram: Telegram{
+			AdminPasswordHashFile: defaultAdminPasswordHashFile,
+			Workers:               defaultBotWorkers,
+		},
+		Database: Database{
+			SQLitePath: "shortlinks.db",
+		},
+		Links: Links{
+			DefaultLifetimeDays: shortener.DefaultLifetimeDays,
+			MaxLifetimeDays:     shortener.DefaultMaxLifetimeDays,
+			RedirectStatus:      http.StatusFound,
+		},
+		Cache: Cache{
+			Size: saving.DefaultCacheSize,
+			TTL:  saving.DefaultCacheTTL,
+		},
+		Clicks: Clicks{
+			QueueSize:     clickqueue.DefaultSize,
+			BatchSize:     clickqueue.DefaultBatchSize,
+			FlushInterval: clickqueue.DefaultFlushInterval,
+		},
+	}
+
+	options := cfg.options()
+
+	flags := flag.NewFlagSet("2links", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "Path to a JSON config file (CONFIG_FILE)")
+
+	flagValues := make(map[string]string)
+	for _, opt := range options {
+		flags.Func(opt.key(), fmt.Sprintf("%s (%s)", opt.usage, opt.env), func(value string) error {
+			flagValues[opt.key()] = value
+			return nil
+		})
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, options); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, opt := range options {
+		value := os.Getenv(opt.env)
+		if value == "" {
+			continue
+		}
+
+		if err := opt.set(value); err != nil {
+			return nil, nil, fmt.Errorf("Invalid %s %q: %w", opt.env, value, err)
+		}
+	}
+
+	for _, opt := range options {
+		value, ok := flagValues[opt.key()]
+		if !ok {
+			continue
+		}
+
+		if err := opt.set(value); err != nil {
+			return nil, nil, fmt.Errorf("Invalid -%s %q: %w", opt.key(), value, err)
+		}
+	}
+
+	if cfg.Domain == "" {
+		cfg.Domain = "http://localhost:" + cfg.Port + "/"
+	} else if !strings.HasSuffix(cfg.Domain, "/") {
+		cfg.Domain += "/"
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+func (c *Config) options() []option {
+	return []option{
+		{"TELEGRAM_BOT_TOKEN", "Telegram bot token", stringValue(&c.Telegram.Token)},
+		{"ADMIN_BOT_TOKEN", "Admin bot token", stringValue(&c.Telegram.AdminToken)},
+		{"ADMIN_PASSWORD_HASH_FILE", "File with the bcrypt hash of the admin password", stringValue(&c.Telegram.AdminPasswordHashFile)},
+		{"WEBHOOK_URL", "Public HTTPS base URL for Telegram webhooks, empty uses long polling", stringValue(&c.Telegram.WebhookURL)},
+		{"WEBHOOK_SECRET", "Secret Telegram sends with every webhook request", stringValue(&c.Telegram.WebhookSecret)},
+		{"BOT_WORKERS", "Updates each bot handles concurrently, one chat at a time", intValue(&c.Telegram.Workers)},
+		{"MY_DOMAIN", "Public base URL of short links", stringValue(&c.Domain)},
+		{"PORT", "Port of the redirect and API server", stringValue(&c.Port)},
+		{"METRICS_PORT", "Internal port for Prometheus metrics", stringValue(&c.MetricsPort)},
+		{"TRUSTED_PROXIES", "Comma-separated CIDRs or IPs of trusted reverse proxies", stringValue(&c.TrustedProxies)},
+		{"GEOIP_DB", "Path to a GeoLite2/GeoIP2 City database", stringValue(&c.GeoIPPath)},
+		{"DB", "Storage: postgres, sqlite or memory", stringValue(&c.Database.Driver)},
+		{"POSTGRES", "Postgres connection string", stringValue(&c.Database.Postgres)},
+		{"POSTGRES_DEFAULT", "Connection string of the default Postgres database", stringValue(&c.Database.PostgresDefault)},
+		{"SQLITE_PATH", "SQLite database file", stringValue(&c.Database.SQLitePath)},
+		{"DEFAULT_LIFETIME", "Lifetime of new links in days", intValue(&c.Links.DefaultLifetimeDays)},
+		{"MAX_LIFETIME", "Maximum link lifetime in days", intValue(&c.Links.MaxLifetimeDays)},
+		{"REDIRECT_STATUS", "Default redirect status: 301, 302, 307 or 308", intValue(&c.Links.RedirectStatus)},
+		{"CODE_GENERATOR", "Short code generator: random, sequential, hashids or words", stringValue(&c.Links.CodeGenerator)},
+		{"CODE_LENGTH", "Short code length", intValue(&c.Links.CodeLength)},
+		{"CODE_SALT", "Salt for the hashids generator", stringValue(&c.Links.CodeSalt)},
+		{"LINK_CACHE_SIZE", "Cached short codes, 0 disables the cache", intValue(&c.Cache.Size)},
+		{"LINK_CACHE_TTL", "Lifetime of cached short codes", durationValue(&c.Cache.TTL)},
+		{"CLICK_QUEUE_SIZE", "Clicks buffered in memory", intValue(&c.Clicks.QueueSize)},
+		{"CLICK_BATCH_SIZE", "Clicks written in one insert", intValue(&c.Clicks.BatchSize)},
+		{"CLICK_FLUSH_INTERVAL", "Maximum delay before queued clicks are written", durationValue(&c.Clicks.FlushInterval)},
+	}
+}
+
+func loadFile(path string, options []option) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read config file: %w", err)
+	}
+
+	var values map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return fmt.Errorf("Failed to parse config file %s: %w", path, err)
+	}
+
+	for _, opt := range options {
+		raw, ok := values[opt.key()]
+		if !ok {
+			continue
+		}
+		delete(values, opt.key())
+
+		var value string
+		switch v := raw.(type) {
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		default:
+			return fmt.Errorf("Invalid %q in %s: must be a string or a number", opt.key(), path)
+		}
+
+		if err := opt.set(value); err != nil {
+			return fmt.Errorf("Invalid %q in %s: %w", opt.key(), path, err)
+		}
+	}
+
+	if len(values) > 0 {
+		return fmt.Errorf("Unknown keys in %s: %s", path, strings.Join(slices.Sorted(maps.Keys(values)), ", "))
+	}
+
+	return nil
+}
+
+// Validate checks everything the server needs; the migrate command only needs
+// Database.Validate.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Telegram.Token == "" {
+		errs = append(errs, errors.New("TELEGRAM_BOT_TOKEN is not set"))
+	}
+
+	if c.Telegram.AdminToken == "" {
+		errs = append(errs, errors.New("ADMIN_BOT_TOKEN is not set"))
+	}
+
+	if err := c.Database.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validatePort("PORT", c.Port); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validatePort("METRICS_PORT", c.MetricsPort); err != nil {
+		errs = append(errs, err)
+	} else if c.MetricsPort == c.Port {
+		errs = append(errs, errors.New("METRICS_PORT must differ from PORT"))
+	}
+
+	if u, err := url.Parse(c.Domain); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("MY_DOMAIN must be an http or https URL, got %q", c.Domain))
+	}
+
+	if c.Telegram.WebhookURL != "" {
+		if u, err := url.Parse(c.Telegram.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("WEBHOOK_URL must be an https URL, got %q", c.Telegram.WebhookURL))
+		}
+
+		if !webhookSecretPattern.MatchString(c.Telegram.WebhookSecret) {
+			errs = append(errs, errors.New("WEBHOOK_SECRET must be 1-256 latin letters, digits, '-' or '_' when WEBHOOK_URL is set"))
+		}
+	}
+
+	if c.Telegram.Workers < 1 {
+		errs = append(errs, errors.New("BOT_WORKERS must be at least 1"))
+	}
+
+	if c.Links.MaxLifetimeDays <= 0 {
+		errs = append(errs, errors.New("MAX_LIFETIME must be positive"))
+	}
+
+	if c.Links.DefaultLifetimeDays <= 0 || c.Links.DefaultLifetimeDays > c.Links.MaxLifetimeDays {
+		errs = append(errs, fmt.Errorf("DEFAULT_LIFETIME must be between 1 and MAX_LIFETIME (%d)", c.Links.MaxLifetimeDays))
+	}
+
+	if !shortener.ValidRedirectStatus(c.Links.RedirectStatus) {
+		errs = append(errs, fmt.Errorf("REDIRECT_STATUS must be one of 301, 302, 307 or 308, got %d", c.Links.RedirectStatus))
+	}
+
+	if c.Links.CodeLength < 0 {
+		errs = append(errs, errors.New("CODE_LENGTH must not be negative"))
+	}
+
+	if c.Cache.Size < 0 || c.Cache.TTL < 0 {
+		errs = append(errs, errors.New("LINK_CACHE_SIZE and LINK_CACHE_TTL must not be negative"))
+	}
+
+	if c.Clicks.QueueSize < 0 || c.Clicks.BatchSize < 0 || c.Clicks.FlushInterval < 0 {
+		errs = append(errs, errors.New("CLICK_QUEUE_SIZE, CLICK_BATCH_SIZE and CLICK_FLUSH_INTERVAL must not be negative"))
+	}
+
+	if limit := saving.MaxClickBatchSize(c.Database.Driver); c.Clicks.BatchSize > limit {
+		errs = append(errs, fmt.Errorf("CLICK_BATCH_SIZE must not exceed %d for DB=%s", limit, c.Database.Driver))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d Database) Validate() error {
+	switch d.Driver {
+	case saving.DriverPostgres:
+		if d.Postgres == "" || d.PostgresDefault == "" {
+			return errors.New("POSTGRES and POSTGRES_DEFAULT must be set for DB=postgres")
+		}
+
+	case saving.DriverSQLite:
+		if d.SQLitePath == "" {
+			return errors.New("SQLITE_PATH must be set for DB=sqlite")
+		}
+
+	case saving.DriverMemory:
+
+	default:
+		return fmt.Errorf("DB must be one of postgres, sqlite or memory, got %q", d.Driver)
+	}
+
+	return nil
+}
+
+func (d Database) Conn() string {
+	switch d.Driver {
+	case saving.DriverPostgres:
+		return d.Postgres
+	case saving.DriverSQLite:
+		return d.SQLitePath
+	}
+
+	return ""
+}
+
+// ShortLinkPrefix is the domain as users write short links, e.g. "2lnx.ru/".
+func (c *Config) ShortLinkPrefix() string {
+	prefix := strings.TrimPrefix(c.Domain, "https://")
+	return strings.TrimPrefix(prefix, "http://")
+}
+
+func validatePort(name, port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s must be a port number, got %q", name, port)
+	}
+
+	return nil
+}
+
+func stringValue(p *string) func(string) error {
+	return func(value string) error {
+		*p = value
+		return nil
+	}
+}
+
+func intValue(p *int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+
+		*p = n
+		return nil
+	}
+}
+
+func durationValue(p *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("must be a duration like 500ms, 1m or 2h")
+		}
+
+		*p = d
+		return nil
+	}
+}
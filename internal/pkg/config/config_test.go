@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv hides the settings of the environment the tests run in.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("CONFIG_FILE", "")
+	for _, opt := range (&Config{}).options() {
+		t.Setenv(opt.env, "")
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return path
+}
+
+func validConfig(t *testing.T) *Config {
+	t.Helper()
+
+	cfg, _, err := Load([]string{"-telegram-bot-token", "token", "-admin-bot-token", "admin", "-db", "memory"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	return cfg
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg := validConfig(t)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate = %v", err)
+	}
+
+	if cfg.Port != defaultPort || cfg.Domain != "http://localhost:8080/" || cfg.Links.RedirectStatus != 302 ||
+		cfg.Telegram.Workers != defaultBotWorkers {
+		t.Errorf("defaults = %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+
+	path := writeFile(t, `{"port": 8000, "metrics-port": "9100", "db": "sqlite", "link-cache-ttl": "2m", "my-domain": "https://2l.test"}`)
+	t.Setenv("PORT", "8100")
+	t.Setenv("DB", "memory")
+
+	cfg, args, err := Load([]string{"-config", path, "-port", "8200", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.Port != "8200" || cfg.MetricsPort != "9100" || cfg.Database.Driver != "memory" || cfg.Cache.TTL != 2*time.Minute {
+		t.Errorf("flags, environment and file were not applied in order: %+v", cfg)
+	}
+
+	if cfg.Domain != "https://2l.test/" || cfg.ShortLinkPrefix() != "2l.test/" {
+		t.Errorf("Domain = %q, ShortLinkPrefix = %q", cfg.Domain, cfg.ShortLinkPrefix())
+	}
+
+	if !slices.Equal(args, []string{"migrate", "up"}) {
+		t.Errorf("args = %q, want [migrate up]", args)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	clearEnv(t)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown file key", nil, []string{"-config", writeFile(t, `{"prot": 8080}`)}, "Unknown keys"},
+		{"file value type", nil, []string{"-config", writeFile(t, `{"port": true}`)}, `Invalid "port"`},
+		{"broken file", nil, []string{"-config", writeFile(t, `{`)}, "Failed to parse config file"},
+		{"environment number", map[string]string{"BOT_WORKERS": "many"}, nil, "Invalid BOT_WORKERS"},
+		{"flag duration", nil, []string{"-click-flush-interval", "soon"}, "Invalid -click-flush-interval"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			if _, _, err := Load(test.args); err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Load = %v, want an error with %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	clearEnv(t)
+
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   string
+	}{
+		{"bot token", func(cfg *Config) { cfg.Telegram.Token = "" }, "TELEGRAM_BOT_TOKEN"},
+		{"database", func(cfg *Config) { cfg.Database.Driver = "mysql" }, "DB must be one of"},
+		{"postgres", func(cfg *Config) { cfg.Database.Driver = "postgres" }, "POSTGRES and POSTGRES_DEFAULT"},
+		{"port", func(cfg *Config) { cfg.Port = "http" }, "PORT must be a port number"},
+		{"metrics port", func(cfg *Config) { cfg.MetricsPort = cfg.Port }, "METRICS_PORT must differ"},
+		{"domain", func(cfg *Config) { cfg.Domain = "2l.test/" }, "MY_DOMAIN"},
+		{"webhook url", func(cfg *Config) { cfg.Telegram.WebhookURL = "http://2l.test" }, "WEBHOOK_URL"},
+		{"webhook secret", func(cfg *Config) { cfg.Telegram.WebhookURL = "https://2l.test" }, "WEBHOOK_SECRET"},
+		{"workers", func(cfg *Config) { cfg.Telegram.Workers = 0 }, "BOT_WORKERS"},
+		{"lifetime", func(cfg *Config) { cfg.Links.DefaultLifetimeDays = cfg.Links.MaxLifetimeDays + 1 }, "DEFAULT_LIFETIME"},
+		{"redirect status", func(cfg *Config) { cfg.Links.RedirectStatus = 303 }, "REDIRECT_STATUS"},
+		{"cache", func(cfg *Config) { cfg.Cache.TTL = -time.Second }, "LINK_CACHE_TTL"},
+		{"batch size", func(cfg *Config) { cfg.Database.Driver, cfg.Clicks.BatchSize = "sqlite", 5000 }, "CLICK_BATCH_SIZE must not exceed 4095"},
+	}
+
+	for _, test := range tests {
+		cfg := validConfig(t)
+		test.change(cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: Validate = %v, want an error with %q", test.name, err, test.want)
+		}
+	}
+
+	cfg := validConfig(t)
+	cfg.Telegram.Token, cfg.Port = "", "0"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "TELEGRAM_BOT_TOKEN") || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("Validate = %v, want every problem reported", err)
+	}
+}
@@ -0,0 +1,62 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type Location struct {
+	Country string
+	City    string
+}
+
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+type record struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open GeoIP database %s: %w", path, err)
+	}
+
+	return &DB{reader: reader}, nil
+}
+
+func (g *DB) Lookup(ipAddress string) Location {
+	if g == nil {
+		return Location{}
+	}
+
+	ip := net.ParseIP(strings.Trim(ipAddress, "[]"))
+	if ip == nil {
+		return Location{}
+	}
+
+	var rec record
+	if err := g.reader.Lookup(ip, &rec); err != nil {
+		return Location{}
+	}
+
+	return Location{Country: rec.Country.ISOCode, City: rec.City.Names["en"]}
+}
+
+func (g *DB) Close() error {
+	if g == nil {
+		return nil
+	}
+
+	return g.reader.Close()
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A poller is considered disconnected when its last successful long poll is
+// older than this, which covers a request hanging without an error.
+const pollerStaleAfter = 2 * time.Minute
+
+var (
+	ErrNotConnected = errors.New("Not connected yet")
+	ErrStale        = errors.New("No successful poll recently")
+)
+
+type PollerStatus struct {
+	Name        string
+	Err         error
+	LastSuccess time.Time
+}
+
+type poller struct {
+	err         error
+	lastSuccess time.Time
+}
+
+var (
+	mu      sync.RWMutex
+	pollers = make(map[string]*poller)
+)
+
+func Watch(names ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, name := range names {
+		if _, ok := pollers[name]; !ok {
+			pollers[name] = &poller{err: ErrNotConnected}
+		}
+	}
+}
+
+func ReportPoll(name string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	p, ok := pollers[name]
+	if !ok {
+		p = &poller{}
+		pollers[name] = p
+	}
+
+	p.err = err
+	if err == nil {
+		p.lastSuccess = time.Now()
+	}
+}
+
+func Pollers() []PollerStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	statuses := make([]PollerStatus, 0, len(pollers))
+	for name, p := range pollers {
+		status := PollerStatus{Name: name, Err: p.err, LastSuccess: p.lastSuccess}
+		if status.Err == nil && time.Since(p.lastSuccess) > pollerStaleAfter {
+			status.Err = ErrStale
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
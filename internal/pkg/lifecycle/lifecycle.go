@@ -0,0 +1,89 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const ShutdownTimeout = 15 * time.Second
+
+// Manager runs long-lived subsystems under one context. The context is
+// cancelled on SIGINT/SIGTERM or as soon as any subsystem returns, so one
+// failing part brings the whole process down cleanly instead of limping on.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	errs []error
+}
+
+func New() *Manager {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	return &Manager{ctx: ctx, cancel: cancel}
+}
+
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.cancel()
+
+		log.Printf("Starting %s", name)
+		err := run(m.ctx)
+		if err == nil && m.ctx.Err() == nil {
+			err = errors.New("Stopped unexpectedly")
+		}
+
+		if err != nil {
+			log.Printf("%s failed: %v", name, err)
+			m.mu.Lock()
+			m.errs = append(m.errs, fmt.Errorf("%s: %w", name, err))
+			m.mu.Unlock()
+			return
+		}
+
+		log.Printf("%s stopped", name)
+	}()
+}
+
+func (m *Manager) Wait() error {
+	<-m.ctx.Done()
+	log.Printf("Shutting down")
+	m.wg.Wait()
+	m.cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return errors.Join(m.errs...)
+}
+
+func ServeHTTP(ctx context.Context, srv *http.Server) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("Failed to shut down %s: %w", srv.Addr, err)
+	}
+
+	return nil
+}
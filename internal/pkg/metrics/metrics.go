@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"2links/internal/pkg/clickqueue"
+	"2links/internal/pkg/lifecycle"
+	"2links/internal/pkg/saving"
+	"context"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortlinks"
+
+const (
+	OutcomeFound     = "found"
+	OutcomeNotFound  = "not_found"
+	OutcomeExpired   = "expired"
+	OutcomePending   = "pending"
+	OutcomeExhausted = "exhausted"
+	OutcomePassword  = "password"
+	OutcomeError     = "error"
+)
+
+var (
+	redirects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Short link requests by outcome.",
+	}, []string{"outcome"})
+
+	redirectDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redirect_duration_seconds",
+		Help:      "Time spent handling short link requests by outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"outcome"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of storage calls by method.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"method"})
+
+	botUpdates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bot_updates_total",
+		Help:      "Telegram updates processed by bot and command.",
+	}, []string{"bot", "command"})
+
+	telegramErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_errors_total",
+		Help:      "Failed Telegram Bot API requests by bot and method.",
+	}, []string{"bot", "method"})
+)
+
+func Serve(ctx context.Context, port string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	log.Printf("Metrics are served on port %s", port)
+	return lifecycle.ServeHTTP(ctx, &http.Server{Addr: ":" + port, Handler: mux})
+}
+
+func ObserveRedirect(outcome string, start time.Time) {
+	redirects.WithLabelValues(outcome).Inc()
+	redirectDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+func BotUpdate(bot, command string) {
+	botUpdates.WithLabelValues(bot, command).Inc()
+}
+
+func TelegramClient(bot string) *http.Client {
+	return &http.Client{Transport: telegramTransport{bot: bot, next: http.DefaultTransport}}
+}
+
+type telegramTransport struct {
+	bot  string
+	next http.RoundTripper
+}
+
+func (t telegramTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		telegramErrors.WithLabelValues(t.bot, path.Base(req.URL.Path)).Inc()
+	}
+
+	return resp, err
+}
+
+func RegisterCache(cache *saving.Cache) {
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "link_cache_hits_total",
+			Help:      "Short code lookups served from the cache.",
+		}, func() float64 { return float64(cache.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "link_cache_misses_total",
+			Help:      "Short code lookups that went to the database.",
+		}, func() float64 { return float64(cache.Stats().Misses) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "link_cache_entries",
+			Help:      "Short codes currently cached.",
+		}, func() float64 { return float64(cache.Stats().Size) }),
+	)
+}
+
+func RegisterClickQueue(queue *clickqueue.Queue) {
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clicks_enqueued_total",
+			Help:      "Clicks accepted by the ingestion queue.",
+		}, func() float64 { return float64(queue.Stats().Enqueued) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clicks_flushed_total",
+			Help:      "Clicks written to the database.",
+		}, func() float64 { return float64(queue.Stats().Flushed) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clicks_dropped_total",
+			Help:      "Clicks dropped because the queue was full or closed.",
+		}, func() float64 { return float64(queue.Stats().Dropped) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "clicks_failed_total",
+			Help:      "Clicks lost because a batch insert failed.",
+		}, func() float64 { return float64(queue.Stats().Failed) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "click_queue_pending",
+			Help:      "Clicks waiting in the queue.",
+		}, func() float64 { return float64(queue.Stats().Pending) }),
+	)
+}
@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"2links/internal/pkg/saving"
+	"time"
+)
+
+type instrumentedStore struct {
+	saving.Store
+}
+
+func InstrumentStore(store saving.Store) saving.Store {
+	return instrumentedStore{Store: store}
+}
+
+func observe(method string, start time.Time) {
+	dbQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (s instrumentedStore) Ping() error {
+	defer observe("Ping", time.Now())
+	return s.Store.Ping()
+}
+
+func (s instrumentedStore) UserInBase(id int64) bool {
+	defer observe("UserInBase", time.Now())
+	return s.Store.UserInBase(id)
+}
+
+func (s instrumentedStore) AddUser(id int64) error {
+	defer observe("AddUser", time.Now())
+	return s.Store.AddUser(id)
+}
+
+func (s instrumentedStore) SaveLink(link saving.NewLink) error {
+	defer observe("SaveLink", time.Now())
+	return s.Store.SaveLink(link)
+}
+
+func (s instrumentedStore) LinkInBase(link string) bool {
+	defer observe("LinkInBase", time.Now())
+	return s.Store.LinkInBase(link)
+}
+
+func (s instrumentedStore) FindLink(link string) (int, error) {
+	defer observe("FindLink", time.Now())
+	return s.Store.FindLink(link)
+}
+
+func (s instrumentedStore) ShowMyLinks(id int64) ([]saving.Link, error) {
+	defer observe("ShowMyLinks", time.Now())
+	return s.Store.ShowMyLinks(id)
+}
+
+func (s instrumentedStore) GetUserLink(userID int64, shortURL string) (saving.Link, error) {
+	defer observe("GetUserLink", time.Now())
+	return s.Store.GetUserLink(userID, shortURL)
+}
+
+func (s instrumentedStore) GetOriginalURL(shortLink string) (saving.Link, error) {
+	defer observe("GetOriginalURL", time.Now())
+	return s.Store.GetOriginalURL(shortLink)
+}
+
+func (s instrumentedStore) UpdateLinkExpiry(userID int64, shortURL string, newExpiry time.Time) error {
+	defer observe("UpdateLinkExpiry", time.Now())
+	return s.Store.UpdateLinkExpiry(userID, shortURL, newExpiry)
+}
+
+func (s instrumentedStore) SetLinkPassword(userID int64, shortURL string, hash string) error {
+	defer observe("SetLinkPassword", time.Now())
+	return s.Store.SetLinkPassword(userID, shortURL, hash)
+}
+
+func (s instrumentedStore) SetClickBudget(userID int64, shortURL string, maxClicks int) error {
+	defer observe("SetClickBudget", time.Now())
+	return s.Store.SetClickBudget(userID, shortURL, maxClicks)
+}
+
+func (s instrumentedStore) SetLinkActivation(userID int64, shortURL string, activatesAt time.Time) error {
+	defer observe("SetLinkActivation", time.Now())
+	return s.Store.SetLinkActivation(userID, shortURL, activatesAt)
+}
+
+func (s instrumentedStore) SetRedirectStatus(userID int64, shortURL string, status int) error {
+	defer observe("SetRedirectStatus", time.Now())
+	return s.Store.SetRedirectStatus(userID, shortURL, status)
+}
+
+func (s instrumentedStore) SetPassthrough(userID int64, shortURL string, enabled bool) error {
+	defer observe("SetPassthrough", time.Now())
+	return s.Store.SetPassthrough(userID, shortURL, enabled)
+}
+
+func (s instrumentedStore) SetLinkRule(userID int64, shortURL string, rule saving.LinkRule) error {
+	defer observe("SetLinkRule", time.Now())
+	return s.Store.SetLinkRule(userID, shortURL, rule)
+}
+
+func (s instrumentedStore) DeleteLinkRule(userID int64, shortURL string, kind, match string) error {
+	defer observe("DeleteLinkRule", time.Now())
+	return s.Store.DeleteLinkRule(userID, shortURL, kind, match)
+}
+
+func (s instrumentedStore) GetLinkRules(linkID int) ([]saving.LinkRule, error) {
+	defer observe("GetLinkRules", time.Now())
+	return s.Store.GetLinkRules(linkID)
+}
+
+func (s instrumentedStore) AddLinkVariant(userID int64, shortURL string, variant saving.LinkVariant) error {
+	defer observe("AddLinkVariant", time.Now())
+	return s.Store.AddLinkVariant(userID, shortURL, variant)
+}
+
+func (s instrumentedStore) DeleteLinkVariant(userID int64, shortURL string, variantID int) error {
+	defer observe("DeleteLinkVariant", time.Now())
+	return s.Store.DeleteLinkVariant(userID, shortURL, variantID)
+}
+
+func (s instrumentedStore) GetLinkVariants(linkID int) ([]saving.LinkVariant, error) {
+	defer observe("GetLinkVariants", time.Now())
+	return s.Store.GetLinkVariants(linkID)
+}
+
+func (s instrumentedStore) UpdateLinkURL(userID int64, shortURL string, newURL string) error {
+	defer observe("UpdateLinkURL", time.Now())
+	return s.Store.UpdateLinkURL(userID, shortURL, newURL)
+}
+
+func (s instrumentedStore) UpdateLink(userID int64, shortURL string, update saving.LinkUpdate) error {
+	defer observe("UpdateLink", time.Now())
+	return s.Store.UpdateLink(userID, shortURL, update)
+}
+
+func (s instrumentedStore) GetLinkRevisions(userID int64, shortURL string) ([]saving.LinkRevision, error) {
+	defer observe("GetLinkRevisions", time.Now())
+	return s.Store.GetLinkRevisions(userID, shortURL)
+}
+
+func (s instrumentedStore) ConsumeClick(linkID int) (bool, error) {
+	defer observe("ConsumeClick", time.Now())
+	return s.Store.ConsumeClick(linkID)
+}
+
+func (s instrumentedStore) DeleteLink(shortCode string) error {
+	defer observe("DeleteLink", time.Now())
+	return s.Store.DeleteLink(shortCode)
+}
+
+func (s instrumentedStore) DeleteUserLink(userID int64, shortURL string) error {
+	defer observe("DeleteUserLink", time.Now())
+	return s.Store.DeleteUserLink(userID, shortURL)
+}
+
+func (s instrumentedStore) NextCodeSequence() (int64, error) {
+	defer observe("NextCodeSequence", time.Now())
+	return s.Store.NextCodeSequence()
+}
+
+func (s instrumentedStore) SaveClick(click saving.Click) error {
+	defer observe("SaveClick", time.Now())
+	return s.Store.SaveClick(click)
+}
+
+func (s instrumentedStore) SaveClicks(clicks []saving.Click) error {
+	defer observe("SaveClicks", time.Now())
+	return s.Store.SaveClicks(clicks)
+}
+
+func (s instrumentedStore) GetClicksByUser(userID int64) (map[string]saving.LinkClicks, error) {
+	defer observe("GetClicksByUser", time.Now())
+	return s.Store.GetClicksByUser(userID)
+}
+
+func (s instrumentedStore) GetGeoClicks(userID int64, shortURL string) ([]saving.GeoClicks, error) {
+	defer observe("GetGeoClicks", time.Now())
+	return s.Store.GetGeoClicks(userID, shortURL)
+}
+
+func (s instrumentedStore) GetVariantClicks(userID int64, shortURL string) ([]saving.LinkVariant, error) {
+	defer observe("GetVariantClicks", time.Now())
+	return s.Store.GetVariantClicks(userID, shortURL)
+}
+
+func (s instrumentedStore) SuspectLink(id int, link string) error {
+	defer observe("SuspectLink", time.Now())
+	return s.Store.SuspectLink(id, link)
+}
+
+func (s instrumentedStore) GetSuspectLinks() ([]saving.Link, error) {
+	defer observe("GetSuspectLinks", time.Now())
+	return s.Store.GetSuspectLinks()
+}
+
+func (s instrumentedStore) DeleteSuspectLink(link string) error {
+	defer observe("DeleteSuspectLink", time.Now())
+	return s.Store.DeleteSuspectLink(link)
+}
+
+func (s instrumentedStore) SaveReview(ans string, id int64) error {
+	defer observe("SaveReview", time.Now())
+	return s.Store.SaveReview(ans, id)
+}
+
+func (s instrumentedStore) GetReviews() ([]string, error) {
+	defer observe("GetReviews", time.Now())
+	return s.Store.GetReviews()
+}
+
+func (s instrumentedStore) SaveFeedback(ans int, id int64) error {
+	defer observe("SaveFeedback", time.Now())
+	return s.Store.SaveFeedback(ans, id)
+}
+
+func (s instrumentedStore) GetGrade() (float32, error) {
+	defer observe("GetGrade", time.Now())
+	return s.Store.GetGrade()
+}
+
+func (s instrumentedStore) GetSummaryStatistics() (saving.Statistics, error) {
+	defer observe("GetSummaryStatistics", time.Now())
+	return s.Store.GetSummaryStatistics()
+}
+
+func (s instrumentedStore) SaveAPIKey(userID int64, prefix, hash, scope string) error {
+	defer observe("SaveAPIKey", time.Now())
+	return s.Store.SaveAPIKey(userID, prefix, hash, scope)
+}
+
+func (s instrumentedStore) GetAPIKey(prefix string) (saving.APIKey, error) {
+	defer observe("GetAPIKey", time.Now())
+	return s.Store.GetAPIKey(prefix)
+}
+
+func (s instrumentedStore) ListAPIKeys(userID int64) ([]saving.APIKey, error) {
+	defer observe("ListAPIKeys", time.Now())
+	return s.Store.ListAPIKeys(userID)
+}
+
+func (s instrumentedStore) DeleteAPIKey(userID int64, prefix string) error {
+	defer observe("DeleteAPIKey", time.Now())
+	return s.Store.DeleteAPIKey(userID, prefix)
+}
+
+func (s instrumentedStore) TouchAPIKey(prefix string) error {
+	defer observe("TouchAPIKey", time.Now())
+	return s.Store.TouchAPIKey(prefix)
+}
+
+func (s instrumentedStore) SaveUTMPreset(userID int64, preset saving.UTMPreset) error {
+	defer observe("SaveUTMPreset", time.Now())
+	return s.Store.SaveUTMPreset(userID, preset)
+}
+
+func (s instrumentedStore) GetUTMPreset(userID int64, name string) (saving.UTMPreset, error) {
+	defer observe("GetUTMPreset", time.Now())
+	return s.Store.GetUTMPreset(userID, name)
+}
+
+func (s instrumentedStore) ListUTMPresets(userID int64) ([]saving.UTMPreset, error) {
+	defer observe("ListUTMPresets", time.Now())
+	return s.Store.ListUTMPresets(userID)
+}
+
+func (s instrumentedStore) DeleteUTMPreset(userID int64, name string) error {
+	defer observe("DeleteUTMPreset", time.Now())
+	return s.Store.DeleteUTMPreset(userID, name)
+}
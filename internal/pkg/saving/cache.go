@@ -0,0 +1,298 @@
+package saving
+
+import (
+	"container/list"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultCacheSize = 10000
+	DefaultCacheTTL  = time.Minute
+
+	maxNegativeCacheTTL = 10 * time.Second
+)
+
+// Cache keeps recently resolved short codes, including unknown ones, with the
+// rules and variants of their links in front of another Store. Every method
+// that changes a link by its short code drops the cached entry, so it must
+// wrap the Store that all writers share.
+type Cache struct {
+	Store
+
+	mu          sync.Mutex
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*list.Element
+	byID        map[int]*list.Element
+	order       *list.List
+
+	// generation changes with every invalidation. A lookup that raced with a
+	// write may have read the old row, so it is only cached when the
+	// generation it started with is still current.
+	generation uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Size   int
+}
+
+type cacheEntry struct {
+	code     string
+	link     Link
+	found    bool
+	targets  bool
+	rules    []LinkRule
+	variants []LinkVariant
+	expires  time.Time
+}
+
+func NewCache(store Store, capacity int, ttl time.Duration) *Cache {
+	if capacity <= 0 {
+		capacity = DefaultCacheSize
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return &Cache{
+		Store:       store,
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: min(ttl, maxNegativeCacheTTL),
+		entries:     make(map[string]*list.Element),
+		byID:        make(map[int]*list.Element),
+		order:       list.New(),
+	}
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Size: c.order.Len()}
+}
+
+func (c *Cache) GetOriginalURL(shortLink string) (Link, error) {
+	if entry, ok := c.lookup(shortLink); ok {
+		c.hits.Add(1)
+		if !entry.found {
+			return Link{}, ErrLinkNotFound
+		}
+		return entry.link, nil
+	}
+
+	c.misses.Add(1)
+	generation := c.currentGeneration()
+	link, err := c.Store.GetOriginalURL(shortLink)
+	if errors.Is(err, ErrLinkNotFound) {
+		c.put(cacheEntry{code: shortLink, expires: time.Now().Add(c.negativeTTL)}, generation)
+	} else if err == nil {
+		c.put(c.withTargets(cacheEntry{code: shortLink, link: link, found: true, expires: time.Now().Add(c.ttl)}), generation)
+	}
+
+	return link, err
+}
+
+func (c *Cache) GetLinkRules(linkID int) ([]LinkRule, error) {
+	if entry, ok := c.lookupID(linkID); ok {
+		return slices.Clone(entry.rules), nil
+	}
+
+	return c.Store.GetLinkRules(linkID)
+}
+
+func (c *Cache) GetLinkVariants(linkID int) ([]LinkVariant, error) {
+	if entry, ok := c.lookupID(linkID); ok {
+		return slices.Clone(entry.variants), nil
+	}
+
+	return c.Store.GetLinkVariants(linkID)
+}
+
+// withTargets loads the rules and variants of the entry's link, which every
+// redirect needs next. On errors the entry is cached without them.
+func (c *Cache) withTargets(entry cacheEntry) cacheEntry {
+	rules, err := c.Store.GetLinkRules(entry.link.ID)
+	if err != nil {
+		return entry
+	}
+
+	variants, err := c.Store.GetLinkVariants(entry.link.ID)
+	if err != nil {
+		return entry
+	}
+
+	entry.rules, entry.variants, entry.targets = rules, variants, true
+	return entry
+}
+
+func (c *Cache) SaveLink(link NewLink) error {
+	defer c.invalidate(link.ShortURL)
+	return c.Store.SaveLink(link)
+}
+
+func (c *Cache) UpdateLink(userID int64, shortURL string, update LinkUpdate) error {
+	defer c.invalidate(shortURL)
+	return c.Store.UpdateLink(userID, shortURL, update)
+}
+
+func (c *Cache) UpdateLinkExpiry(userID int64, shortURL string, newExpiry time.Time) error {
+	defer c.invalidate(shortURL)
+	return c.Store.UpdateLinkExpiry(userID, shortURL, newExpiry)
+}
+
+func (c *Cache) SetLinkPassword(userID int64, shortURL string, hash string) error {
+	defer c.invalidate(shortURL)
+	return c.Store.SetLinkPassword(userID, shortURL, hash)
+}
+
+func (c *Cache) SetClickBudget(userID int64, shortURL string, maxClicks int) error {
+	defer c.invalidate(shortURL)
+	return c.Store.SetClickBudget(userID, shortURL, maxClicks)
+}
+
+func (c *Cache) SetLinkActivation(userID int64, shortURL string, activatesAt time.Time) error {
+	defer c.invalidate(shortURL)
+	return c.Store.SetLinkActivation(userID, shortURL, activatesAt)
+}
+
+func (c *Cache) SetRedirectStatus(userID int64, shortURL string, status int) error {
+	defer c.invalidate(shortURL)
+	return c.Store.SetRedirectStatus(userID, shortURL, status)
+}
+
+func (c *Cache) SetPassthrough(userID int64, shortURL string, enabled bool) error {
+	defer c.invalidate(shortURL)
+	return c.Store.SetPassthrough(userID, shortURL, enabled)
+}
+
+func (c *Cache) UpdateLinkURL(userID int64, shortURL string, newURL string) error {
+	defer c.invalidate(shortURL)
+	return c.Store.UpdateLinkURL(userID, shortURL, newURL)
+}
+
+func (c *Cache) DeleteLink(shortCode string) error {
+	defer c.invalidate(shortCode)
+	return c.Store.DeleteLink(shortCode)
+}
+
+func (c *Cache) DeleteUserLink(userID int64, shortURL string) error {
+	defer c.invalidate(shortURL)
+	return c.Store.DeleteUserLink(userID, shortURL)
+}
+
+func (c *Cache) DeleteSuspectLink(link string) error {
+	defer c.invalidate(link)
+	return c.Store.DeleteSuspectLink(link)
+}
+
+func (c *Cache) SetLinkRule(userID int64, shortURL string, rule LinkRule) error {
+	defer c.invalidate(shortURL)
+	return c.Store.SetLinkRule(userID, shortURL, rule)
+}
+
+func (c *Cache) DeleteLinkRule(userID int64, shortURL string, kind, match string) error {
+	defer c.invalidate(shortURL)
+	return c.Store.DeleteLinkRule(userID, shortURL, kind, match)
+}
+
+func (c *Cache) AddLinkVariant(userID int64, shortURL string, variant LinkVariant) error {
+	defer c.invalidate(shortURL)
+	return c.Store.AddLinkVariant(userID, shortURL, variant)
+}
+
+func (c *Cache) DeleteLinkVariant(userID int64, shortURL string, variantID int) error {
+	defer c.invalidate(shortURL)
+	return c.Store.DeleteLinkVariant(userID, shortURL, variantID)
+}
+
+func (c *Cache) lookup(code string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.live(c.entries[code])
+}
+
+func (c *Cache) lookupID(linkID int) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.live(c.byID[linkID])
+	return entry, ok && entry.targets
+}
+
+// live returns the entry of elem unless it has expired. c.mu must be held.
+func (c *Cache) live(elem *list.Element) (cacheEntry, bool) {
+	if elem == nil {
+		return cacheEntry{}, false
+	}
+
+	entry := elem.Value.(cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return cacheEntry{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (c *Cache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+func (c *Cache) put(entry cacheEntry, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.entries[entry.code]; ok {
+		c.remove(elem)
+	}
+
+	elem := c.order.PushFront(entry)
+	c.entries[entry.code] = elem
+	if entry.found {
+		c.byID[entry.link.ID] = elem
+	}
+
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) invalidate(code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if elem, ok := c.entries[code]; ok {
+		c.remove(elem)
+	}
+}
+
+// remove drops elem from the cache. c.mu must be held.
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(cacheEntry)
+	delete(c.entries, entry.code)
+	if entry.found && c.byID[entry.link.ID] == elem {
+		delete(c.byID, entry.link.ID)
+	}
+}
@@ -0,0 +1,95 @@
+package saving
+
+import (
+	"testing"
+)
+
+// racingStore runs a write while a read is in flight, after the read has
+// fetched its row.
+type racingStore struct {
+	Store
+	write func()
+}
+
+func (s *racingStore) GetOriginalURL(shortLink string) (Link, error) {
+	link, err := s.Store.GetOriginalURL(shortLink)
+	if write := s.write; write != nil {
+		s.write = nil
+		write()
+	}
+
+	return link, err
+}
+
+func TestCacheSkipsRowsReadDuringWrites(t *testing.T) {
+	memory := NewMemory()
+	newLink(t, memory, "abc")
+
+	store := &racingStore{Store: memory}
+	cache := NewCache(store, 0, 0)
+	store.write = func() {
+		if err := cache.SetLinkPassword(owner, "abc", "hash"); err != nil {
+			t.Fatalf("SetLinkPassword: %v", err)
+		}
+	}
+
+	if _, err := cache.GetOriginalURL("abc"); err != nil {
+		t.Fatalf("GetOriginalURL: %v", err)
+	}
+
+	link, err := cache.GetOriginalURL("abc")
+	if err != nil || link.PasswordHash != "hash" {
+		t.Errorf("GetOriginalURL = %+v, %v, want the link with its password", link, err)
+	}
+}
+
+func TestCacheKeepsRulesAndVariants(t *testing.T) {
+	memory := NewMemory()
+	newLink(t, memory, "abc")
+	cache := NewCache(memory, 0, 0)
+
+	rule := LinkRule{Kind: "device", Match: "ios", TargetURL: "https://apps.apple.com"}
+	if err := cache.SetLinkRule(owner, "abc", rule); err != nil {
+		t.Fatalf("SetLinkRule: %v", err)
+	}
+	if err := cache.AddLinkVariant(owner, "abc", LinkVariant{TargetURL: "https://a.example", Weight: 1}); err != nil {
+		t.Fatalf("AddLinkVariant: %v", err)
+	}
+
+	link, err := cache.GetOriginalURL("abc")
+	if err != nil {
+		t.Fatalf("GetOriginalURL: %v", err)
+	}
+
+	// Changes behind the cache's back stay unseen until the entry is dropped.
+	if err := memory.DeleteLinkRule(owner, "abc", rule.Kind, rule.Match); err != nil {
+		t.Fatalf("DeleteLinkRule: %v", err)
+	}
+	if rules, err := cache.GetLinkRules(link.ID); err != nil || len(rules) != 1 {
+		t.Errorf("GetLinkRules = %v, %v, want the cached rule", rules, err)
+	}
+
+	variants, err := cache.GetLinkVariants(link.ID)
+	if err != nil || len(variants) != 1 {
+		t.Fatalf("GetLinkVariants = %v, %v, want the cached variant", variants, err)
+	}
+
+	if err := cache.DeleteLinkVariant(owner, "abc", variants[0].ID); err != nil {
+		t.Fatalf("DeleteLinkVariant: %v", err)
+	}
+
+	if _, err := cache.GetOriginalURL("abc"); err != nil {
+		t.Fatalf("GetOriginalURL: %v", err)
+	}
+
+	if rules, err := cache.GetLinkRules(link.ID); err != nil || len(rules) != 0 {
+		t.Errorf("GetLinkRules after invalidation = %v, %v", rules, err)
+	}
+	if variants, err := cache.GetLinkVariants(link.ID); err != nil || len(variants) != 0 {
+		t.Errorf("GetLinkVariants after invalidation = %v, %v", variants, err)
+	}
+
+	if stats := cache.Stats(); stats.Misses != 2 || stats.Hits != 0 {
+		t.Errorf("Stats = %+v, want 2 misses", stats)
+	}
+}
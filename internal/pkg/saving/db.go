@@ -1,88 +1,80 @@
 package saving
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+const pingTimeout = 2 * time.Second
+
 const (
 	queryCreateDB = `
 CREATE DATABASE shortlinks;`
 
-	queryCreateTables = `
-
-CREATE TABLE IF NOT EXISTS users (
-    id SERIAL PRIMARY KEY,               
-    telegram_id BIGINT UNIQUE NOT NULL   
-);
-
-
-CREATE TABLE IF NOT EXISTS links (
-    id SERIAL PRIMARY KEY,                
-    user_id INTEGER NOT NULL,             
-    original_url TEXT NOT NULL,           
-    short_url VARCHAR(255) UNIQUE NOT NULL, 
-    expires_at TIMESTAMP,                 
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-	FOREIGN KEY (user_id) REFERENCES users(telegram_id) ON DELETE CASCADE
-);
-
-
-CREATE TABLE IF NOT EXISTS clicks (
-    id SERIAL PRIMARY KEY,                 
-    link_id INTEGER NOT NULL,              
-    clicked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, 
-    ip_address VARCHAR(45),                
-    user_agent TEXT,                        
-    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE 
-);
-
-CREATE TABLE IF NOT EXISTS suspect_links (
-    id SERIAL PRIMARY KEY,                         
-    short_url VARCHAR(255) UNIQUE NOT NULL, 
-	FOREIGN KEY (id) REFERENCES links(id) ON DELETE CASCADE
-);
-
-
-CREATE TABLE IF NOT EXISTS reviews (
-    id SERIAL PRIMARY KEY,               
-    user_id INTEGER NOT NULL,   
-	review TEXT NOT NULL,                    
-    FOREIGN KEY (user_id) REFERENCES users(telegram_id) ON DELETE CASCADE
-);
-
-CREATE TABLE IF NOT EXISTS feedback (
-    id SERIAL PRIMARY KEY,               
-    user_id INTEGER NOT NULL,   
-	grade INTEGER NOT NULL,                    
-    FOREIGN KEY (user_id) REFERENCES users(telegram_id) ON DELETE CASCADE
-);`
-
 	queryCheckUser = `SELECT EXISTS (SELECT 1 FROM users WHERE telegram_id = $1)`
 
 	queryUniqueLink = `SELECT EXISTS (SELECT 1 FROM links WHERE short_url = $1);`
 
-	queryShowLink = `SELECT short_url, original_url, created_at, expires_at
+	linkColumns = `id, short_url, original_url, created_at, expires_at, COALESCE(password_hash, ''),
+					COALESCE(max_clicks, 0), COALESCE(clicks_left, 0), activates_at, COALESCE(revision_id, 0),
+					COALESCE(redirect_status, 0), COALESCE(passthrough, FALSE)`
+
+	queryShowLink = `SELECT ` + linkColumns + `
 					FROM links
 					WHERE user_id = $1
 					ORDER BY created_at DESC;`
 
 	queryAddUser = `INSERT INTO users (telegram_id) VALUES ($1);`
 
-	queryAddLink = `INSERT INTO links (user_id, original_url, short_url, expires_at) VALUES ($1, $2, $3, $4);`
+	queryAddLink = `INSERT INTO links (user_id, original_url, short_url, expires_at, activates_at, password_hash,
+						max_clicks, clicks_left, redirect_status, passthrough)
+					VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, 0), NULLIF($7, 0), NULLIF($8, 0), $9)
+					RETURNING id;`
+
+	queryAddLinkRule = `INSERT INTO link_rules (link_id, kind, match_value, target_url) VALUES ($1, $2, $3, $4)
+						ON CONFLICT (link_id, kind, match_value) DO UPDATE SET target_url = $4`
+
+	queryAddLinkVariant = `INSERT INTO link_variants (link_id, target_url, weight) VALUES ($1, $2, $3)`
+
+	queryAddRevision = `INSERT INTO link_revisions (link_id, original_url) VALUES ($1, $2) RETURNING id;`
+
+	querySetRevision = `UPDATE links SET original_url = $1, revision_id = $2, updated_at = $3 WHERE id = $4`
+
+	querySelectUserLink = `SELECT id FROM links WHERE short_url = $1 AND user_id = $2`
 
-	queryAddClick = `INSERT INTO clicks (link_id, ip_address, user_agent) VALUES ($1, $2, $3);`
+	queryGetRevisions = `
+						SELECT r.id, r.original_url, r.created_at, COUNT(c.id)
+						FROM link_revisions r
+						JOIN links l ON l.id = r.link_id
+						LEFT JOIN clicks c ON c.revision_id = r.id
+						WHERE l.short_url = $1 AND l.user_id = $2
+						GROUP BY r.id, r.original_url, r.created_at
+						ORDER BY r.id DESC`
 
-	queryAddSuspect = `INSERT INTO suspect_links (id, short_url) VALUES ($1, $2);`
+	queryNextCode = `SELECT nextval('short_code_seq')`
 
-	queryAddFeedback = `DELETE FROM feedback 
-						WHERE user_id = $1;
-						INSERT INTO feedback (user_id, grade) VALUES ($1, $2);`
+	queryAddClicks = `INSERT INTO clicks (link_id, revision_id, variant_id, ip_address, user_agent, matched_rule, country, city)
+						VALUES `
+
+	clickRow = `($%d, NULLIF($%d, 0), NULLIF($%d, 0), $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''))`
+
+	clickColumnsCount = 8
+
+	postgresMaxParams = 65535
+	sqliteMaxParams   = 32766
+
+	queryAddSuspect = `INSERT INTO suspect_links (link_id, short_url) VALUES ($1, $2);`
+
+	queryDeleteFeedback = `DELETE FROM feedback WHERE user_id = $1;`
+
+	queryAddFeedback = `INSERT INTO feedback (user_id, grade) VALUES ($1, $2);`
 
 	queryAddReview = `INSERT INTO reviews (user_id, review) VALUES ($1, $2);`
 
@@ -90,7 +82,7 @@ CREATE TABLE IF NOT EXISTS feedback (
 
 	queryFindDB = `SELECT COUNT(*) = 1 FROM pg_catalog.pg_database WHERE datname = $1`
 
-	queryGetURL = `SELECT original_url, id, expires_at FROM links WHERE short_url = $1`
+	queryGetURL = `SELECT ` + linkColumns + ` FROM links WHERE short_url = $1`
 
 	queryGetClicks = `
 						SELECT l.short_url, l.original_url, COUNT(c.id)
@@ -99,13 +91,106 @@ CREATE TABLE IF NOT EXISTS feedback (
 						WHERE l.user_id = $1
 						GROUP BY l.short_url, l.original_url`
 
+	queryGetGeoClicks = `
+						SELECT COALESCE(c.country, ''), COALESCE(c.city, ''), COUNT(c.id)
+						FROM clicks c
+						JOIN links l ON l.id = c.link_id
+						WHERE l.short_url = $1 AND l.user_id = $2
+						GROUP BY c.country, c.city
+						ORDER BY COUNT(c.id) DESC`
+
+	queryGetUserLink = `SELECT ` + linkColumns + `
+					FROM links
+					WHERE short_url = $1 AND user_id = $2`
+
+	// querySetBudget keeps the clicks already used: the new budget only adds
+	// or takes away the remaining ones.
+	querySetBudget = `UPDATE links SET max_clicks = NULLIF($1, 0),
+						clicks_left = CASE
+							WHEN $1 = 0 THEN NULL
+							WHEN $1 > COALESCE(max_clicks - clicks_left, 0) THEN $1 - COALESCE(max_clicks - clicks_left, 0)
+							ELSE 0
+						END,
+						updated_at = $2
+					WHERE short_url = $3 AND user_id = $4`
+
+	querySetActivation = `UPDATE links SET activates_at = $1, updated_at = $2 WHERE short_url = $3 AND user_id = $4`
+
+	querySetRedirect = `UPDATE links SET redirect_status = NULLIF($1, 0), updated_at = $2 WHERE short_url = $3 AND user_id = $4`
+
+	querySetPassthrough = `UPDATE links SET passthrough = $1, updated_at = $2 WHERE short_url = $3 AND user_id = $4`
+
+	querySetRule = `INSERT INTO link_rules (link_id, kind, match_value, target_url)
+						SELECT id, $3, $4, $5 FROM links WHERE short_url = $1 AND user_id = $2
+						ON CONFLICT (link_id, kind, match_value) DO UPDATE SET target_url = $5`
+
+	queryDeleteRule = `DELETE FROM link_rules
+						WHERE link_id = (SELECT id FROM links WHERE short_url = $1 AND user_id = $2)
+						AND kind = $3 AND match_value = $4`
+
+	queryDeleteLinkRules = `DELETE FROM link_rules WHERE link_id = $1`
+
+	queryGetRules = `SELECT kind, match_value, target_url FROM link_rules WHERE link_id = $1 ORDER BY id`
+
+	queryAddVariant = `INSERT INTO link_variants (link_id, target_url, weight)
+						SELECT id, $3, $4 FROM links WHERE short_url = $1 AND user_id = $2`
+
+	queryDeleteVariant = `DELETE FROM link_variants
+						WHERE link_id = (SELECT id FROM links WHERE short_url = $1 AND user_id = $2)
+						AND id = $3`
+
+	queryDeleteLinkVariant = `DELETE FROM link_variants WHERE id = $1`
+
+	queryGetVariants = `SELECT id, target_url, weight FROM link_variants WHERE link_id = $1 ORDER BY id`
+
+	queryGetVariantClicks = `
+						SELECT v.id, v.target_url, v.weight, COUNT(c.id)
+						FROM link_variants v
+						JOIN links l ON l.id = v.link_id
+						LEFT JOIN clicks c ON c.variant_id = v.id
+						WHERE l.short_url = $1 AND l.user_id = $2
+						GROUP BY v.id, v.target_url, v.weight
+						ORDER BY v.id`
+
+	queryConsumeClick = `UPDATE links SET clicks_left = clicks_left - 1 WHERE id = $1 AND clicks_left > 0`
+
+	querySetPassword = `UPDATE links SET password_hash = NULLIF($1, ''), updated_at = $2 WHERE short_url = $3 AND user_id = $4`
+
 	queryDeleteLink = `DELETE FROM links WHERE short_url = $1`
 
-	queryUpdateExp = `UPDATE links SET expires_at = $1 WHERE short_url = $2 AND user_id = $3`
+	queryDeleteUserLink = `DELETE FROM links WHERE short_url = $1 AND user_id = $2`
+
+	queryUpdateExp = `UPDATE links SET expires_at = $1, updated_at = $2 WHERE short_url = $3 AND user_id = $4`
 
 	queryGetSuspect = `SELECT sl.short_url, l.original_url
 						FROM suspect_links sl
-						JOIN links l ON sl.id = l.id;`
+						JOIN links l ON sl.link_id = l.id;`
+
+	queryAddAPIKey = `INSERT INTO api_keys (user_id, prefix, key_hash, scope) VALUES ($1, $2, $3, $4);`
+
+	queryGetAPIKey = `SELECT prefix, user_id, key_hash, scope, created_at, last_used_at FROM api_keys WHERE prefix = $1`
+
+	queryListAPIKeys = `SELECT prefix, user_id, key_hash, scope, created_at, last_used_at
+					FROM api_keys
+					WHERE user_id = $1
+					ORDER BY created_at DESC;`
+
+	queryDeleteAPIKey = `DELETE FROM api_keys WHERE prefix = $1 AND user_id = $2`
+
+	queryTouchAPIKey = `UPDATE api_keys SET last_used_at = $2 WHERE prefix = $1`
+
+	querySaveUTMPreset = `INSERT INTO utm_presets (user_id, name, utm_source, utm_medium, utm_campaign, utm_term, utm_content)
+						VALUES ($1, $2, $3, $4, $5, $6, $7)
+						ON CONFLICT (user_id, name) DO UPDATE SET utm_source = $3, utm_medium = $4, utm_campaign = $5,
+						utm_term = $6, utm_content = $7`
+
+	utmPresetColumns = `name, utm_source, utm_medium, utm_campaign, utm_term, utm_content`
+
+	queryGetUTMPreset = `SELECT ` + utmPresetColumns + ` FROM utm_presets WHERE user_id = $1 AND name = $2`
+
+	queryListUTMPresets = `SELECT ` + utmPresetColumns + ` FROM utm_presets WHERE user_id = $1 ORDER BY name`
+
+	queryDeleteUTMPreset = `DELETE FROM utm_presets WHERE user_id = $1 AND name = $2`
 
 	queryAllUsers = `SELECT COUNT(*) FROM users`
 
@@ -113,40 +198,101 @@ CREATE TABLE IF NOT EXISTS feedback (
 
 	queryAllClicks = `SELECT COUNT(*) FROM clicks`
 
-	queryAllExpired = `SELECT COUNT(*) FROM links WHERE expires_at < NOW()`
+	queryAllExpired = `SELECT COUNT(*) FROM links WHERE expires_at < $1`
 
 	queryGetReviews = `SELECT review FROM reviews ORDER BY id DESC LIMIT 5`
 
 	queryGetGrade = `SELECT AVG(grade) FROM feedback`
 )
 
-type DB struct {
-	Db *sql.DB
-}
+const uniqueViolation = "23505"
 
-type Link struct {
-	ShortURL    string
-	OriginalURL string
-	CreatedAt   time.Time
-	ExpiresAt   time.Time
+// DB writes every time in UTC: the timestamp columns have no time zone, so
+// Postgres would drop the offset and keep the local wall clock time.
+type DB struct {
+	db                *sql.DB
+	driver            string
+	queryNextCode     string
+	isUniqueViolation func(err error) bool
 }
 
 func CreateDB(dbtype string, conn string) (*DB, error) {
-	db, err := sql.Open(dbtype, conn)
+	db, err := Connect(dbtype, conn)
 	if err != nil {
-		log.Fatal("Error connecting to database")
+		return nil, err
 	}
 
-	_, err = db.Exec(queryCreateTables)
+	_, err = db.MigrateUp()
 	if err != nil {
-		log.Fatal("Error creating tables", err)
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func Connect(dbtype string, conn string) (*DB, error) {
+	switch dbtype {
+	case DriverPostgres:
+		db, err := sql.Open(dbtype, conn)
+		if err != nil {
+			return nil, fmt.Errorf("Error connecting to database: %w", err)
+		}
+
+		return &DB{db: db, driver: dbtype, queryNextCode: queryNextCode, isUniqueViolation: isPostgresUniqueViolation}, nil
+
+	case DriverSQLite:
+		return connectSQLite(conn)
+	}
+
+	return nil, fmt.Errorf("Database driver %q does not support migrations", dbtype)
+}
+
+func (s *DB) Close() error {
+	return s.db.Close()
+}
+
+func (s *DB) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("Database is unreachable: %w", err)
 	}
 
-	return &DB{Db: db}, nil
+	return nil
 }
 
-func SaveLink(db *sql.DB, id int64, orig string, short string, exp time.Time) error {
-	_, err := db.Exec(queryAddLink, id, orig, short, exp)
+func (s *DB) SaveLink(link NewLink) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("Error saving link:", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	activatesAt := sql.NullTime{Time: link.ActivatesAt.UTC(), Valid: !link.ActivatesAt.IsZero()}
+
+	var linkID int
+	err = tx.QueryRow(queryAddLink, link.UserID, link.OriginalURL, link.ShortURL, link.ExpiresAt.UTC(), activatesAt,
+		link.PasswordHash, link.MaxClicks, link.RedirectStatus, link.Passthrough).Scan(&linkID)
+	if s.isUniqueViolation(err) {
+		return ErrShortURLTaken
+	} else if err != nil {
+		log.Println("Error saving link:", err)
+		return err
+	}
+
+	err = addRevision(tx, linkID, link.OriginalURL)
+	if err == nil {
+		err = addLinkTargets(tx, linkID, link.Rules, link.Variants)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
 	if err != nil {
 		log.Println("Error saving link:", err)
 		return err
@@ -155,9 +301,202 @@ func SaveLink(db *sql.DB, id int64, orig string, short string, exp time.Time) er
 	return nil
 }
 
-func UserInBase(db *sql.DB, id int64) bool {
+func addLinkTargets(tx *sql.Tx, linkID int, rules []LinkRule, variants []LinkVariant) error {
+	for _, rule := range rules {
+		if _, err := tx.Exec(queryAddLinkRule, linkID, rule.Kind, rule.Match, rule.TargetURL); err != nil {
+			return err
+		}
+	}
+
+	for _, variant := range variants {
+		if _, err := tx.Exec(queryAddLinkVariant, linkID, variant.TargetURL, variant.Weight); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *DB) UpdateLinkURL(userID int64, shortURL string, newURL string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error updating link destination: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	var linkID int
+	err = tx.QueryRow(querySelectUserLink, shortURL, userID).Scan(&linkID)
+	if err == sql.ErrNoRows {
+		return ErrLinkNotFound
+	} else if err != nil {
+		return fmt.Errorf("Database query error: %w", err)
+	}
+
+	if err = addRevision(tx, linkID, newURL); err != nil {
+		return fmt.Errorf("Error updating link destination: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("Error updating link destination: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DB) UpdateLink(userID int64, shortURL string, update LinkUpdate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("Error updating link: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	var linkID int
+	err = tx.QueryRow(querySelectUserLink, shortURL, userID).Scan(&linkID)
+	if err == sql.ErrNoRows {
+		return ErrLinkNotFound
+	} else if err != nil {
+		return fmt.Errorf("Database query error: %w", err)
+	}
+
+	now := time.Now().UTC()
+	exec := func(query string, args ...any) {
+		if err == nil {
+			_, err = tx.Exec(query, args...)
+		}
+	}
+
+	if update.OriginalURL != nil {
+		err = addRevision(tx, linkID, *update.OriginalURL)
+	}
+	if update.ExpiresAt != nil {
+		exec(queryUpdateExp, update.ExpiresAt.UTC(), now, shortURL, userID)
+	}
+	if update.ActivatesAt != nil {
+		exec(querySetActivation, sql.NullTime{Time: update.ActivatesAt.UTC(), Valid: !update.ActivatesAt.IsZero()}, now, shortURL, userID)
+	}
+	if update.PasswordHash != nil {
+		exec(querySetPassword, *update.PasswordHash, now, shortURL, userID)
+	}
+	if update.MaxClicks != nil {
+		exec(querySetBudget, *update.MaxClicks, now, shortURL, userID)
+	}
+	if update.RedirectStatus != nil {
+		exec(querySetRedirect, *update.RedirectStatus, now, shortURL, userID)
+	}
+	if update.Passthrough != nil {
+		exec(querySetPassthrough, *update.Passthrough, now, shortURL, userID)
+	}
+	if update.Rules != nil {
+		exec(queryDeleteLinkRules, linkID)
+		if err == nil {
+			err = addLinkTargets(tx, linkID, *update.Rules, nil)
+		}
+	}
+	if update.Variants != nil && err == nil {
+		err = replaceLinkVariants(tx, linkID, *update.Variants)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error updating link: %w", err)
+	}
+
+	return nil
+}
+
+func replaceLinkVariants(tx *sql.Tx, linkID int, variants []LinkVariant) error {
+	rows, err := tx.Query(queryGetVariants, linkID)
+	if err != nil {
+		return err
+	}
+
+	var existing []LinkVariant
+	for rows.Next() {
+		var variant LinkVariant
+		if err := rows.Scan(&variant.ID, &variant.TargetURL, &variant.Weight); err != nil {
+			rows.Close()
+			return err
+		}
+		existing = append(existing, variant)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	stale, added := diffVariants(existing, variants)
+	for _, variant := range stale {
+		if _, err := tx.Exec(queryDeleteLinkVariant, variant.ID); err != nil {
+			return err
+		}
+	}
+
+	return addLinkTargets(tx, linkID, nil, added)
+}
+
+func addRevision(tx *sql.Tx, linkID int, url string) error {
+	var revisionID int
+	err := tx.QueryRow(queryAddRevision, linkID, url).Scan(&revisionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(querySetRevision, url, revisionID, time.Now().UTC(), linkID)
+	return err
+}
+
+func (s *DB) GetLinkRevisions(userID int64, shortURL string) ([]LinkRevision, error) {
+	rows, err := s.db.Query(queryGetRevisions, shortURL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch link revisions: %w", err)
+	}
+
+	defer rows.Close()
+
+	var revisions []LinkRevision
+	for rows.Next() {
+		var revision LinkRevision
+		if err := rows.Scan(&revision.ID, &revision.OriginalURL, &revision.CreatedAt, &revision.Clicks); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrLinkNotFound
+	}
+
+	return revisions, nil
+}
+
+func (s *DB) NextCodeSequence() (int64, error) {
+	var n int64
+	err := s.db.QueryRow(s.queryNextCode).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get next code sequence value: %w", err)
+	}
+
+	return n, nil
+}
+
+func isPostgresUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+func (s *DB) UserInBase(id int64) bool {
 	var exists bool
-	err := db.QueryRow(queryCheckUser, id).Scan(&exists)
+	err := s.db.QueryRow(queryCheckUser, id).Scan(&exists)
 	if err != nil {
 		log.Println("Error finding user:", err)
 		return false
@@ -166,9 +505,9 @@ func UserInBase(db *sql.DB, id int64) bool {
 	return exists
 }
 
-func LinkInBase(db *sql.DB, link string) bool {
+func (s *DB) LinkInBase(link string) bool {
 	var exists bool
-	err := db.QueryRow(queryUniqueLink, link).Scan(&exists)
+	err := s.db.QueryRow(queryUniqueLink, link).Scan(&exists)
 	if err != nil {
 		log.Println("Error finding link:", err)
 		return false
@@ -177,8 +516,8 @@ func LinkInBase(db *sql.DB, link string) bool {
 	return exists
 }
 
-func AddUser(db *sql.DB, id int64) error {
-	_, err := db.Exec(queryAddUser, id)
+func (s *DB) AddUser(id int64) error {
+	_, err := s.db.Exec(queryAddUser, id)
 	if err != nil {
 		log.Println("Error saving user:", err)
 		return err
@@ -187,8 +526,24 @@ func AddUser(db *sql.DB, id int64) error {
 	return nil
 }
 
-func SaveFeedback(db *sql.DB, ans int, id int64) error {
-	_, err := db.Exec(queryAddFeedback, id, ans)
+func (s *DB) SaveFeedback(ans int, id int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println("Error saving feedback:", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.Exec(queryDeleteFeedback, id)
+	if err == nil {
+		_, err = tx.Exec(queryAddFeedback, id, ans)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
 	if err != nil {
 		log.Println("Error saving feedback:", err)
 		return err
@@ -197,8 +552,8 @@ func SaveFeedback(db *sql.DB, ans int, id int64) error {
 	return nil
 }
 
-func SaveReview(db *sql.DB, ans string, id int64) error {
-	_, err := db.Exec(queryAddReview, id, ans)
+func (s *DB) SaveReview(ans string, id int64) error {
+	_, err := s.db.Exec(queryAddReview, id, ans)
 	if err != nil {
 		log.Println("Error saving review:", err)
 		return err
@@ -206,9 +561,9 @@ func SaveReview(db *sql.DB, ans string, id int64) error {
 	return nil
 }
 
-func FindLink(db *sql.DB, link string) (int, error) {
+func (s *DB) FindLink(link string) (int, error) {
 	var linkID int
-	err := db.QueryRow(querySelectLink, link).Scan(&linkID)
+	err := s.db.QueryRow(querySelectLink, link).Scan(&linkID)
 	if err == sql.ErrNoRows {
 		return 0, nil
 	} else if err != nil {
@@ -218,8 +573,8 @@ func FindLink(db *sql.DB, link string) (int, error) {
 	return linkID, nil
 }
 
-func SuspectLink(db *sql.DB, id int, link string) error {
-	_, err := db.Exec(queryAddSuspect, id, link)
+func (s *DB) SuspectLink(id int, link string) error {
+	_, err := s.db.Exec(queryAddSuspect, id, link)
 	if err != nil {
 		log.Println("Error saving review:", err)
 		return err
@@ -228,8 +583,8 @@ func SuspectLink(db *sql.DB, id int, link string) error {
 	return nil
 }
 
-func ShowMyLinks(db *sql.DB, id int64) ([]Link, error) {
-	rows, err := db.Query(queryShowLink, id)
+func (s *DB) ShowMyLinks(id int64) ([]Link, error) {
+	rows, err := s.db.Query(queryShowLink, id)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch user links: %v", err)
 	}
@@ -239,7 +594,7 @@ func ShowMyLinks(db *sql.DB, id int64) ([]Link, error) {
 	var links []Link
 	for rows.Next() {
 		var link Link
-		if err := rows.Scan(&link.ShortURL, &link.OriginalURL, &link.CreatedAt, &link.ExpiresAt); err != nil {
+		if err := scanLink(rows, &link); err != nil {
 			return nil, fmt.Errorf("Failed to scan row: %v", err)
 		}
 		links = append(links, link)
@@ -252,8 +607,8 @@ func ShowMyLinks(db *sql.DB, id int64) ([]Link, error) {
 	return links, nil
 }
 
-func DeleteLink(db *sql.DB, shortCode string) error {
-	result, err := db.Exec(queryDeleteLink, shortCode)
+func (s *DB) DeleteLink(shortCode string) error {
+	result, err := s.db.Exec(queryDeleteLink, shortCode)
 	if err != nil {
 		return fmt.Errorf("Error deleting link: %w", err)
 	}
@@ -266,40 +621,89 @@ func DeleteLink(db *sql.DB, shortCode string) error {
 	return nil
 }
 
-func SaveClick(db *sql.DB, linkID int, ipAddress, userAgent string) error {
-	_, err := db.Exec(queryAddClick, linkID, ipAddress, userAgent)
+func (s *DB) GetUserLink(userID int64, shortURL string) (Link, error) {
+	var link Link
+	err := scanLink(s.db.QueryRow(queryGetUserLink, shortURL, userID), &link)
+	if err == sql.ErrNoRows {
+		return link, ErrLinkNotFound
+	} else if err != nil {
+		return link, fmt.Errorf("Database query error: %w", err)
+	}
+
+	return link, nil
+}
+
+func (s *DB) DeleteUserLink(userID int64, shortURL string) error {
+	result, err := s.db.Exec(queryDeleteUserLink, shortURL, userID)
 	if err != nil {
-		return fmt.Errorf("Failed to save click: %w", err)
+		return fmt.Errorf("Error deleting link: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
 	}
 
 	return nil
 }
 
-func GetClicksByUser(db *sql.DB, userID int64) (map[string]struct {
-	OriginalURL string
-	Clicks      int
-}, error) {
-	rows, err := db.Query(queryGetClicks, userID)
+func (s *DB) SaveClick(click Click) error {
+	return s.SaveClicks([]Click{click})
+}
+
+// MaxClickBatchSize is the most clicks SaveClicks can write at once: they go
+// into one statement, and the drivers limit the parameters it may have.
+func MaxClickBatchSize(driver string) int {
+	if driver == DriverSQLite {
+		return sqliteMaxParams / clickColumnsCount
+	}
+
+	return postgresMaxParams / clickColumnsCount
+}
+
+func (s *DB) SaveClicks(clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(queryAddClicks)
+	args := make([]any, 0, len(clicks)*clickColumnsCount)
+	for i, click := range clicks {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+
+		n := len(args)
+		fmt.Fprintf(&query, clickRow, n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8)
+		args = append(args, click.LinkID, click.RevisionID, click.VariantID, click.IPAddress, click.UserAgent,
+			click.MatchedRule, click.Country, click.City)
+	}
+
+	_, err := s.db.Exec(query.String(), args...)
+	if err != nil {
+		return fmt.Errorf("Failed to save %d click(s): %w", len(clicks), err)
+	}
+
+	return nil
+}
+
+func (s *DB) GetClicksByUser(userID int64) (map[string]LinkClicks, error) {
+	rows, err := s.db.Query(queryGetClicks, userID)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch clicks: %w", err)
 	}
 
 	defer rows.Close()
 
-	clicks := make(map[string]struct {
-		OriginalURL string
-		Clicks      int
-	})
+	clicks := make(map[string]LinkClicks)
 	for rows.Next() {
 		var shortURL, originalURL string
 		var count int
 		if err := rows.Scan(&shortURL, &originalURL, &count); err != nil {
 			return nil, fmt.Errorf("Failed to scan row: %w", err)
 		}
-		clicks[shortURL] = struct {
-			OriginalURL string
-			Clicks      int
-		}{
+		clicks[shortURL] = LinkClicks{
 			OriginalURL: originalURL,
 			Clicks:      count,
 		}
@@ -308,36 +712,276 @@ func GetClicksByUser(db *sql.DB, userID int64) (map[string]struct {
 	return clicks, nil
 }
 
-func GetOriginalURL(db *sql.DB, shortLink string) (string, int, time.Time, error) {
-	var originalURL string
-	var linkID int
-	var expires_at time.Time
-	err := db.QueryRow(queryGetURL, shortLink).Scan(&originalURL, &linkID, &expires_at)
+func (s *DB) GetGeoClicks(userID int64, shortURL string) ([]GeoClicks, error) {
+	rows, err := s.db.Query(queryGetGeoClicks, shortURL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch clicks by location: %w", err)
+	}
+
+	defer rows.Close()
+
+	var stats []GeoClicks
+	for rows.Next() {
+		var geo GeoClicks
+		if err := rows.Scan(&geo.Country, &geo.City, &geo.Clicks); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		stats = append(stats, geo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (s *DB) GetVariantClicks(userID int64, shortURL string) ([]LinkVariant, error) {
+	rows, err := s.db.Query(queryGetVariantClicks, shortURL, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch clicks by variant: %w", err)
+	}
+
+	defer rows.Close()
+
+	var variants []LinkVariant
+	for rows.Next() {
+		var variant LinkVariant
+		if err := rows.Scan(&variant.ID, &variant.TargetURL, &variant.Weight, &variant.Clicks); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		variants = append(variants, variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	return variants, nil
+}
+
+func (s *DB) GetOriginalURL(shortLink string) (Link, error) {
+	var link Link
+	err := scanLink(s.db.QueryRow(queryGetURL, shortLink), &link)
 	if err == sql.ErrNoRows {
-		return "", 0, expires_at, fmt.Errorf("Short link not found")
+		return link, ErrLinkNotFound
 	} else if err != nil {
-		return "", 0, expires_at, fmt.Errorf("Database query error: %w", err)
+		return link, fmt.Errorf("Database query error: %w", err)
+	}
+
+	return link, nil
+}
+
+func (s *DB) SetLinkPassword(userID int64, shortURL string, hash string) error {
+	result, err := s.db.Exec(querySetPassword, hash, time.Now().UTC(), shortURL, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating link password: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) SetClickBudget(userID int64, shortURL string, maxClicks int) error {
+	result, err := s.db.Exec(querySetBudget, maxClicks, time.Now().UTC(), shortURL, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating click budget: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) ConsumeClick(linkID int) (bool, error) {
+	result, err := s.db.Exec(queryConsumeClick, linkID)
+	if err != nil {
+		return false, fmt.Errorf("Failed to consume click: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+func (s *DB) SetLinkActivation(userID int64, shortURL string, activatesAt time.Time) error {
+	activation := sql.NullTime{Time: activatesAt.UTC(), Valid: !activatesAt.IsZero()}
+	result, err := s.db.Exec(querySetActivation, activation, time.Now().UTC(), shortURL, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating link activation: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) SetRedirectStatus(userID int64, shortURL string, status int) error {
+	result, err := s.db.Exec(querySetRedirect, status, time.Now().UTC(), shortURL, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating redirect status: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) SetPassthrough(userID int64, shortURL string, enabled bool) error {
+	result, err := s.db.Exec(querySetPassthrough, enabled, time.Now().UTC(), shortURL, userID)
+	if err != nil {
+		return fmt.Errorf("Error updating passthrough: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) SetLinkRule(userID int64, shortURL string, rule LinkRule) error {
+	result, err := s.db.Exec(querySetRule, shortURL, userID, rule.Kind, rule.Match, rule.TargetURL)
+	if err != nil {
+		return fmt.Errorf("Error saving link rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) DeleteLinkRule(userID int64, shortURL string, kind, match string) error {
+	result, err := s.db.Exec(queryDeleteRule, shortURL, userID, kind, match)
+	if err != nil {
+		return fmt.Errorf("Error deleting link rule: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) GetLinkRules(linkID int) ([]LinkRule, error) {
+	rows, err := s.db.Query(queryGetRules, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch link rules: %w", err)
+	}
+
+	defer rows.Close()
+
+	var rules []LinkRule
+	for rows.Next() {
+		var rule LinkRule
+		if err := rows.Scan(&rule.Kind, &rule.Match, &rule.TargetURL); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (s *DB) AddLinkVariant(userID int64, shortURL string, variant LinkVariant) error {
+	result, err := s.db.Exec(queryAddVariant, shortURL, userID, variant.TargetURL, variant.Weight)
+	if err != nil {
+		return fmt.Errorf("Error saving link variant: %w", err)
 	}
 
-	return originalURL, linkID, expires_at, nil
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrLinkNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) DeleteLinkVariant(userID int64, shortURL string, variantID int) error {
+	result, err := s.db.Exec(queryDeleteVariant, shortURL, userID, variantID)
+	if err != nil {
+		return fmt.Errorf("Error deleting link variant: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrVariantNotFound
+	}
+
+	return nil
 }
 
-func UpdateLinkExpiry(db *sql.DB, userID int64, shortURL string, newExpiry time.Time) error {
-	result, err := db.Exec(queryUpdateExp, newExpiry, shortURL, userID)
+func (s *DB) GetLinkVariants(linkID int) ([]LinkVariant, error) {
+	rows, err := s.db.Query(queryGetVariants, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch link variants: %w", err)
+	}
+
+	defer rows.Close()
+
+	var variants []LinkVariant
+	for rows.Next() {
+		var variant LinkVariant
+		if err := rows.Scan(&variant.ID, &variant.TargetURL, &variant.Weight); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		variants = append(variants, variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	return variants, nil
+}
+
+func scanLink(row interface{ Scan(...any) error }, link *Link) error {
+	var activatesAt sql.NullTime
+	err := row.Scan(&link.ID, &link.ShortURL, &link.OriginalURL, &link.CreatedAt, &link.ExpiresAt, &link.PasswordHash,
+		&link.MaxClicks, &link.ClicksLeft, &activatesAt, &link.RevisionID,
+		&link.RedirectStatus, &link.Passthrough)
+	link.ActivatesAt = activatesAt.Time
+	return err
+}
+
+func (s *DB) UpdateLinkExpiry(userID int64, shortURL string, newExpiry time.Time) error {
+	result, err := s.db.Exec(queryUpdateExp, newExpiry.UTC(), time.Now().UTC(), shortURL, userID)
 	if err != nil {
 		return fmt.Errorf("Error updating link expiry: %w", err)
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return fmt.Errorf("No link found or not authorized")
+		return ErrLinkNotFound
 	}
 
 	return nil
 }
 
-func GetReviews(db *sql.DB) ([]string, error) {
-	rows, err := db.Query(queryGetReviews)
+func (s *DB) GetReviews() ([]string, error) {
+	rows, err := s.db.Query(queryGetReviews)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to fetch user links: %v", err)
 	}
@@ -360,9 +1004,9 @@ func GetReviews(db *sql.DB) ([]string, error) {
 	return reviews, nil
 }
 
-func GetGrade(db *sql.DB) (float32, error) {
+func (s *DB) GetGrade() (float32, error) {
 	var grade float32
-	err := db.QueryRow(queryGetGrade).Scan(&grade)
+	err := s.db.QueryRow(queryGetGrade).Scan(&grade)
 	if err != nil {
 		log.Println("Error counting grade:", err)
 		return 0, err
@@ -371,8 +1015,8 @@ func GetGrade(db *sql.DB) (float32, error) {
 	return grade, nil
 }
 
-func GetSuspectLinks(db *sql.DB) ([]Link, error) {
-	rows, err := db.Query(queryGetSuspect)
+func (s *DB) GetSuspectLinks() ([]Link, error) {
+	rows, err := s.db.Query(queryGetSuspect)
 	if err != nil {
 		return nil, err
 	}
@@ -391,35 +1035,25 @@ func GetSuspectLinks(db *sql.DB) ([]Link, error) {
 	return links, nil
 }
 
-func GetSummaryStatistics(db *sql.DB) (struct {
-	Users        int
-	Links        int
-	Clicks       int
-	ExpiredLinks int
-}, error) {
-	var stats struct {
-		Users        int
-		Links        int
-		Clicks       int
-		ExpiredLinks int
-	}
+func (s *DB) GetSummaryStatistics() (Statistics, error) {
+	var stats Statistics
 
-	err := db.QueryRow(queryAllUsers).Scan(&stats.Users)
+	err := s.db.QueryRow(queryAllUsers).Scan(&stats.Users)
 	if err != nil {
 		return stats, err
 	}
 
-	err = db.QueryRow(queryAllLinks).Scan(&stats.Links)
+	err = s.db.QueryRow(queryAllLinks).Scan(&stats.Links)
 	if err != nil {
 		return stats, err
 	}
 
-	err = db.QueryRow(queryAllClicks).Scan(&stats.Clicks)
+	err = s.db.QueryRow(queryAllClicks).Scan(&stats.Clicks)
 	if err != nil {
 		return stats, err
 	}
 
-	err = db.QueryRow(queryAllExpired).Scan(&stats.ExpiredLinks)
+	err = s.db.QueryRow(queryAllExpired, time.Now().UTC()).Scan(&stats.ExpiredLinks)
 	if err != nil {
 		return stats, err
 	}
@@ -427,8 +1061,8 @@ func GetSummaryStatistics(db *sql.DB) (struct {
 	return stats, nil
 }
 
-func DeleteSuspectLink(db *sql.DB, link string) error {
-	_, err := db.Exec(queryDeleteLink, link)
+func (s *DB) DeleteSuspectLink(link string) error {
+	_, err := s.db.Exec(queryDeleteLink, link)
 	return err
 }
 
@@ -475,3 +1109,136 @@ func CreateDatabaseIfNotExists(dbName string, dbtype string, postgres string) er
 
 	return nil
 }
+
+func (s *DB) SaveAPIKey(userID int64, prefix, hash, scope string) error {
+	_, err := s.db.Exec(queryAddAPIKey, userID, prefix, hash, scope)
+	if err != nil {
+		log.Println("Error saving API key:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *DB) GetAPIKey(prefix string) (APIKey, error) {
+	var key APIKey
+	err := s.db.QueryRow(queryGetAPIKey, prefix).Scan(&key.Prefix, &key.UserID, &key.Hash, &key.Scope, &key.CreatedAt, &key.LastUsedAt)
+	if err == sql.ErrNoRows {
+		return key, ErrAPIKeyNotFound
+	} else if err != nil {
+		return key, fmt.Errorf("Database query error: %w", err)
+	}
+
+	return key, nil
+}
+
+func (s *DB) ListAPIKeys(userID int64) ([]APIKey, error) {
+	rows, err := s.db.Query(queryListAPIKeys, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch API keys: %v", err)
+	}
+
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.Prefix, &key.UserID, &key.Hash, &key.Scope, &key.CreatedAt, &key.LastUsedAt); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %v", err)
+	}
+
+	return keys, nil
+}
+
+func (s *DB) DeleteAPIKey(userID int64, prefix string) error {
+	result, err := s.db.Exec(queryDeleteAPIKey, prefix, userID)
+	if err != nil {
+		return fmt.Errorf("Error deleting API key: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (s *DB) TouchAPIKey(prefix string) error {
+	_, err := s.db.Exec(queryTouchAPIKey, prefix, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("Failed to update API key usage: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DB) SaveUTMPreset(userID int64, preset UTMPreset) error {
+	_, err := s.db.Exec(querySaveUTMPreset, userID, preset.Name,
+		preset.Source, preset.Medium, preset.Campaign, preset.Term, preset.Content)
+	if err != nil {
+		return fmt.Errorf("Failed to save UTM preset: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DB) GetUTMPreset(userID int64, name string) (UTMPreset, error) {
+	var preset UTMPreset
+	err := scanUTMPreset(s.db.QueryRow(queryGetUTMPreset, userID, name), &preset)
+	if err == sql.ErrNoRows {
+		return preset, ErrUTMPresetNotFound
+	} else if err != nil {
+		return preset, fmt.Errorf("Database query error: %w", err)
+	}
+
+	return preset, nil
+}
+
+func (s *DB) ListUTMPresets(userID int64) ([]UTMPreset, error) {
+	rows, err := s.db.Query(queryListUTMPresets, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch UTM presets: %w", err)
+	}
+
+	defer rows.Close()
+
+	var presets []UTMPreset
+	for rows.Next() {
+		var preset UTMPreset
+		if err := scanUTMPreset(rows, &preset); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		presets = append(presets, preset)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	return presets, nil
+}
+
+func (s *DB) DeleteUTMPreset(userID int64, name string) error {
+	result, err := s.db.Exec(queryDeleteUTMPreset, userID, name)
+	if err != nil {
+		return fmt.Errorf("Error deleting UTM preset: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrUTMPresetNotFound
+	}
+
+	return nil
+}
+
+func scanUTMPreset(row interface{ Scan(...any) error }, preset *UTMPreset) error {
+	return row.Scan(&preset.Name, &preset.Source, &preset.Medium, &preset.Campaign, &preset.Term, &preset.Content)
+}
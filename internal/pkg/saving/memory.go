@@ -0,0 +1,842 @@
+package saving
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const reviewsLimit = 5
+
+type Memory struct {
+	mu sync.RWMutex
+
+	users          map[int64]bool
+	links          map[string]*memoryLink
+	nextLinkID     int
+	nextRevisionID int
+	nextVariantID  int
+	clicks         map[int]int
+	revisionClicks map[int]int
+	variantClicks  map[int]int
+	geoClicks      map[int]map[geoKey]int
+	suspects       map[string]int
+	reviews        []string
+	feedback       map[int64]int
+	apiKeys        map[string]*APIKey
+	utmPresets     map[int64]map[string]UTMPreset
+	codeSeq        int64
+}
+
+type geoKey struct {
+	country string
+	city    string
+}
+
+type memoryLink struct {
+	Link
+	userID    int64
+	revisions []LinkRevision
+	rules     []LinkRule
+	variants  []LinkVariant
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:          make(map[int64]bool),
+		links:          make(map[string]*memoryLink),
+		clicks:         make(map[int]int),
+		revisionClicks: make(map[int]int),
+		variantClicks:  make(map[int]int),
+		geoClicks:      make(map[int]map[geoKey]int),
+		suspects:       make(map[string]int),
+		feedback:       make(map[int64]int),
+		apiKeys:        make(map[string]*APIKey),
+		utmPresets:     make(map[int64]map[string]UTMPreset),
+		codeSeq:        238327,
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) Ping() error {
+	return nil
+}
+
+func (m *Memory) UserInBase(id int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.users[id]
+}
+
+func (m *Memory) AddUser(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.users[id] {
+		return fmt.Errorf("User %d already exists", id)
+	}
+
+	m.users[id] = true
+	return nil
+}
+
+func (m *Memory) SaveLink(link NewLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.users[link.UserID] {
+		return fmt.Errorf("User %d does not exist", link.UserID)
+	}
+
+	if _, ok := m.links[link.ShortURL]; ok {
+		return ErrShortURLTaken
+	}
+
+	m.nextLinkID++
+	l := &memoryLink{
+		Link: Link{
+			ID:             m.nextLinkID,
+			ShortURL:       link.ShortURL,
+			CreatedAt:      time.Now(),
+			ExpiresAt:      link.ExpiresAt,
+			PasswordHash:   link.PasswordHash,
+			MaxClicks:      link.MaxClicks,
+			ClicksLeft:     link.MaxClicks,
+			ActivatesAt:    link.ActivatesAt,
+			RedirectStatus: link.RedirectStatus,
+			Passthrough:    link.Passthrough,
+		},
+		userID: link.UserID,
+	}
+
+	for _, rule := range link.Rules {
+		l.setRule(rule)
+	}
+
+	for _, variant := range link.Variants {
+		m.nextVariantID++
+		l.variants = append(l.variants, LinkVariant{ID: m.nextVariantID, TargetURL: variant.TargetURL, Weight: variant.Weight})
+	}
+
+	m.addRevision(l, link.OriginalURL)
+	m.links[link.ShortURL] = l
+	return nil
+}
+
+func (m *Memory) UpdateLinkURL(userID int64, shortURL string, newURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	m.addRevision(l, newURL)
+	return nil
+}
+
+func (m *Memory) UpdateLink(userID int64, shortURL string, update LinkUpdate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	if update.OriginalURL != nil {
+		m.addRevision(l, *update.OriginalURL)
+	}
+	if update.ExpiresAt != nil {
+		l.ExpiresAt = *update.ExpiresAt
+	}
+	if update.ActivatesAt != nil {
+		l.ActivatesAt = *update.ActivatesAt
+	}
+	if update.PasswordHash != nil {
+		l.PasswordHash = *update.PasswordHash
+	}
+	if update.MaxClicks != nil {
+		l.setBudget(*update.MaxClicks)
+	}
+	if update.RedirectStatus != nil {
+		l.RedirectStatus = *update.RedirectStatus
+	}
+	if update.Passthrough != nil {
+		l.Passthrough = *update.Passthrough
+	}
+	if update.Rules != nil {
+		l.rules = nil
+		for _, rule := range *update.Rules {
+			l.setRule(rule)
+		}
+	}
+	if update.Variants != nil {
+		stale, added := diffVariants(l.variants, *update.Variants)
+		for _, variant := range stale {
+			l.variants = slices.DeleteFunc(l.variants, func(v LinkVariant) bool { return v.ID == variant.ID })
+		}
+		for _, variant := range added {
+			m.nextVariantID++
+			l.variants = append(l.variants, LinkVariant{ID: m.nextVariantID, TargetURL: variant.TargetURL, Weight: variant.Weight})
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) addRevision(l *memoryLink, url string) {
+	m.nextRevisionID++
+	l.OriginalURL = url
+	l.RevisionID = m.nextRevisionID
+	l.revisions = append(l.revisions, LinkRevision{ID: m.nextRevisionID, OriginalURL: url, CreatedAt: time.Now()})
+}
+
+func (m *Memory) GetLinkRevisions(userID int64, shortURL string) ([]LinkRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return nil, ErrLinkNotFound
+	}
+
+	revisions := make([]LinkRevision, 0, len(l.revisions))
+	for i := len(l.revisions) - 1; i >= 0; i-- {
+		revision := l.revisions[i]
+		revision.Clicks = m.revisionClicks[revision.ID]
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func (m *Memory) LinkInBase(link string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.links[link]
+	return ok
+}
+
+func (m *Memory) FindLink(link string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[link]
+	if !ok {
+		return 0, nil
+	}
+
+	return l.ID, nil
+}
+
+func (m *Memory) ShowMyLinks(id int64) ([]Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var links []Link
+	for _, l := range m.links {
+		if l.userID == id {
+			links = append(links, l.Link)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+
+	return links, nil
+}
+
+func (m *Memory) GetUserLink(userID int64, shortURL string) (Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return Link{}, ErrLinkNotFound
+	}
+
+	return l.Link, nil
+}
+
+func (m *Memory) GetOriginalURL(shortLink string) (Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[shortLink]
+	if !ok {
+		return Link{}, ErrLinkNotFound
+	}
+
+	return l.Link, nil
+}
+
+func (m *Memory) UpdateLinkExpiry(userID int64, shortURL string, newExpiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.ExpiresAt = newExpiry
+	return nil
+}
+
+func (m *Memory) SetLinkPassword(userID int64, shortURL string, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.PasswordHash = hash
+	return nil
+}
+
+func (m *Memory) SetClickBudget(userID int64, shortURL string, maxClicks int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.setBudget(maxClicks)
+	return nil
+}
+
+// setBudget keeps the clicks already used, like the databases do.
+func (l *memoryLink) setBudget(maxClicks int) {
+	var used int
+	if l.MaxClicks > 0 {
+		used = l.MaxClicks - l.ClicksLeft
+	}
+
+	l.MaxClicks = maxClicks
+	l.ClicksLeft = max(maxClicks-used, 0)
+}
+
+func (m *Memory) SetLinkActivation(userID int64, shortURL string, activatesAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.ActivatesAt = activatesAt
+	return nil
+}
+
+func (m *Memory) SetRedirectStatus(userID int64, shortURL string, status int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.RedirectStatus = status
+	return nil
+}
+
+func (m *Memory) SetPassthrough(userID int64, shortURL string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.Passthrough = enabled
+	return nil
+}
+
+func (m *Memory) SetLinkRule(userID int64, shortURL string, rule LinkRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	l.setRule(rule)
+	return nil
+}
+
+func (l *memoryLink) setRule(rule LinkRule) {
+	for i, existing := range l.rules {
+		if existing.Kind == rule.Kind && existing.Match == rule.Match {
+			l.rules[i] = rule
+			return
+		}
+	}
+
+	l.rules = append(l.rules, rule)
+}
+
+func (m *Memory) DeleteLinkRule(userID int64, shortURL string, kind, match string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrRuleNotFound
+	}
+
+	for i, existing := range l.rules {
+		if existing.Kind == kind && existing.Match == match {
+			l.rules = append(l.rules[:i], l.rules[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRuleNotFound
+}
+
+func (m *Memory) GetLinkRules(linkID int) ([]LinkRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, l := range m.links {
+		if l.ID == linkID {
+			return append([]LinkRule(nil), l.rules...), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *Memory) AddLinkVariant(userID int64, shortURL string, variant LinkVariant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	m.nextVariantID++
+	l.variants = append(l.variants, LinkVariant{ID: m.nextVariantID, TargetURL: variant.TargetURL, Weight: variant.Weight})
+	return nil
+}
+
+func (m *Memory) DeleteLinkVariant(userID int64, shortURL string, variantID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrVariantNotFound
+	}
+
+	for i, existing := range l.variants {
+		if existing.ID == variantID {
+			// The clicks are kept like in the databases, where they only lose
+			// the reference to the variant. Variant IDs are never reused.
+			l.variants = append(l.variants[:i], l.variants[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrVariantNotFound
+}
+
+func (m *Memory) GetLinkVariants(linkID int) ([]LinkVariant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, l := range m.links {
+		if l.ID == linkID {
+			return append([]LinkVariant(nil), l.variants...), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *Memory) ConsumeClick(linkID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range m.links {
+		if l.ID == linkID {
+			if l.ClicksLeft <= 0 {
+				return false, nil
+			}
+
+			l.ClicksLeft--
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *Memory) DeleteLink(shortCode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.links[shortCode]; !ok {
+		return fmt.Errorf("No link found to delete")
+	}
+
+	m.deleteLink(shortCode)
+	return nil
+}
+
+func (m *Memory) DeleteUserLink(userID int64, shortURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return ErrLinkNotFound
+	}
+
+	m.deleteLink(shortURL)
+	return nil
+}
+
+func (m *Memory) deleteLink(shortURL string) {
+	l := m.links[shortURL]
+	for _, revision := range l.revisions {
+		delete(m.revisionClicks, revision.ID)
+	}
+	for _, variant := range l.variants {
+		delete(m.variantClicks, variant.ID)
+	}
+	delete(m.clicks, l.ID)
+	delete(m.geoClicks, l.ID)
+	delete(m.suspects, shortURL)
+	delete(m.links, shortURL)
+}
+
+func (m *Memory) NextCodeSequence() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.codeSeq++
+	return m.codeSeq, nil
+}
+
+func (m *Memory) SaveClick(click Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clicks[click.LinkID]++
+	if m.geoClicks[click.LinkID] == nil {
+		m.geoClicks[click.LinkID] = make(map[geoKey]int)
+	}
+	m.geoClicks[click.LinkID][geoKey{click.Country, click.City}]++
+	if click.RevisionID != 0 {
+		m.revisionClicks[click.RevisionID]++
+	}
+	if click.VariantID != 0 {
+		m.variantClicks[click.VariantID]++
+	}
+	return nil
+}
+
+func (m *Memory) SaveClicks(clicks []Click) error {
+	for _, click := range clicks {
+		if err := m.SaveClick(click); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) GetClicksByUser(userID int64) (map[string]LinkClicks, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clicks := make(map[string]LinkClicks)
+	for short, l := range m.links {
+		if l.userID == userID {
+			clicks[short] = LinkClicks{OriginalURL: l.OriginalURL, Clicks: m.clicks[l.ID]}
+		}
+	}
+
+	return clicks, nil
+}
+
+func (m *Memory) GetGeoClicks(userID int64, shortURL string) ([]GeoClicks, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return nil, nil
+	}
+
+	var stats []GeoClicks
+	for key, count := range m.geoClicks[l.ID] {
+		stats = append(stats, GeoClicks{Country: key.country, City: key.city, Clicks: count})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Clicks > stats[j].Clicks
+	})
+
+	return stats, nil
+}
+
+func (m *Memory) GetVariantClicks(userID int64, shortURL string) ([]LinkVariant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.links[shortURL]
+	if !ok || l.userID != userID {
+		return nil, nil
+	}
+
+	variants := append([]LinkVariant(nil), l.variants...)
+	for i := range variants {
+		variants[i].Clicks = m.variantClicks[variants[i].ID]
+	}
+
+	return variants, nil
+}
+
+func (m *Memory) SuspectLink(id int, link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.suspects[link]; ok {
+		return fmt.Errorf("Link %s is already reported", link)
+	}
+
+	m.suspects[link] = id
+	return nil
+}
+
+func (m *Memory) GetSuspectLinks() ([]Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var links []Link
+	for short := range m.suspects {
+		if l, ok := m.links[short]; ok {
+			links = append(links, Link{ShortURL: short, OriginalURL: l.OriginalURL})
+		}
+	}
+
+	return links, nil
+}
+
+func (m *Memory) DeleteSuspectLink(link string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.links[link]; ok {
+		m.deleteLink(link)
+	}
+
+	return nil
+}
+
+func (m *Memory) SaveReview(ans string, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reviews = append(m.reviews, ans)
+	return nil
+}
+
+func (m *Memory) GetReviews() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var reviews []string
+	for i := len(m.reviews) - 1; i >= 0 && len(reviews) < reviewsLimit; i-- {
+		reviews = append(reviews, m.reviews[i])
+	}
+
+	return reviews, nil
+}
+
+func (m *Memory) SaveFeedback(ans int, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.feedback[id] = ans
+	return nil
+}
+
+func (m *Memory) GetGrade() (float32, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.feedback) == 0 {
+		return 0, fmt.Errorf("No feedback yet")
+	}
+
+	var sum int
+	for _, grade := range m.feedback {
+		sum += grade
+	}
+
+	return float32(sum) / float32(len(m.feedback)), nil
+}
+
+func (m *Memory) GetSummaryStatistics() (Statistics, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := Statistics{Users: len(m.users), Links: len(m.links)}
+	for _, count := range m.clicks {
+		stats.Clicks += count
+	}
+
+	now := time.Now()
+	for _, l := range m.links {
+		if l.ExpiresAt.Before(now) {
+			stats.ExpiredLinks++
+		}
+	}
+
+	return stats, nil
+}
+
+func (m *Memory) SaveAPIKey(userID int64, prefix, hash, scope string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.apiKeys[prefix]; ok {
+		return fmt.Errorf("API key %s already exists", prefix)
+	}
+
+	m.apiKeys[prefix] = &APIKey{Prefix: prefix, UserID: userID, Hash: hash, Scope: scope, CreatedAt: time.Now()}
+	return nil
+}
+
+func (m *Memory) GetAPIKey(prefix string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[prefix]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	return *key, nil
+}
+
+func (m *Memory) ListAPIKeys(userID int64) ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []APIKey
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, *key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (m *Memory) DeleteAPIKey(userID int64, prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[prefix]
+	if !ok || key.UserID != userID {
+		return ErrAPIKeyNotFound
+	}
+
+	delete(m.apiKeys, prefix)
+	return nil
+}
+
+func (m *Memory) TouchAPIKey(prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key, ok := m.apiKeys[prefix]; ok {
+		key.LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	return nil
+}
+
+func (m *Memory) SaveUTMPreset(userID int64, preset UTMPreset) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.users[userID] {
+		return fmt.Errorf("User %d does not exist", userID)
+	}
+
+	if m.utmPresets[userID] == nil {
+		m.utmPresets[userID] = make(map[string]UTMPreset)
+	}
+
+	m.utmPresets[userID][preset.Name] = preset
+	return nil
+}
+
+func (m *Memory) GetUTMPreset(userID int64, name string) (UTMPreset, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	preset, ok := m.utmPresets[userID][name]
+	if !ok {
+		return UTMPreset{}, ErrUTMPresetNotFound
+	}
+
+	return preset, nil
+}
+
+func (m *Memory) ListUTMPresets(userID int64) ([]UTMPreset, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var presets []UTMPreset
+	for _, preset := range m.utmPresets[userID] {
+		presets = append(presets, preset)
+	}
+
+	sort.Slice(presets, func(i, j int) bool {
+		return presets[i].Name < presets[j].Name
+	})
+
+	return presets, nil
+}
+
+func (m *Memory) DeleteUTMPreset(userID int64, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.utmPresets[userID][name]; !ok {
+		return ErrUTMPresetNotFound
+	}
+
+	delete(m.utmPresets[userID], name)
+	return nil
+}
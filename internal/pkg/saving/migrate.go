@@ -0,0 +1,207 @@
+package saving
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	queryCreateMigrations = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);`
+
+	queryAppliedMigrations = `SELECT version, applied_at FROM schema_migrations ORDER BY version`
+
+	queryAddMigration = `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`
+
+	queryDeleteMigration = `DELETE FROM schema_migrations WHERE version = $1`
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version   int
+	Name      string
+	Up        string
+	Down      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func loadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read migrations for %s: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("Unexpected migration file %s", entry.Name())
+		}
+
+		versionPart, title, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("Migration %04d_%s must have both up and down scripts", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func (s *DB) MigrationStatus() ([]Migration, error) {
+	migrations, err := loadMigrations(s.driver)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(queryCreateMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create schema_migrations: %w", err)
+	}
+
+	rows, err := s.db.Query(queryAppliedMigrations)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch applied migrations: %w", err)
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("Failed to scan row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("Row iteration error: %w", err)
+	}
+
+	for i := range migrations {
+		migrations[i].AppliedAt, migrations[i].Applied = applied[migrations[i].Version]
+	}
+
+	return migrations, nil
+}
+
+func (s *DB) MigrateUp() (int, error) {
+	migrations, err := s.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.Applied {
+			continue
+		}
+
+		err = s.runMigration(m.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(queryAddMigration, m.Version, m.Name)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("Failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+func (s *DB) MigrateDown(steps int) (int, error) {
+	migrations, err := s.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if !m.Applied {
+			continue
+		}
+
+		err = s.runMigration(m.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(queryDeleteMigration, m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("Failed to revert migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		log.Printf("Reverted migration %04d_%s", m.Version, m.Name)
+		count++
+	}
+
+	return count, nil
+}
+
+func (s *DB) runMigration(script string, record func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS feedback;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS suspect_links;
+DROP TABLE IF EXISTS clicks;
+DROP TABLE IF EXISTS links;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1 @@
+CREATE SEQUENCE IF NOT EXISTS short_code_seq START 238328;
@@ -0,0 +1,6 @@
+ALTER TABLE suspect_links DROP CONSTRAINT IF EXISTS suspect_links_link_id_fkey;
+UPDATE suspect_links SET id = -link_id;
+UPDATE suspect_links SET id = -id;
+ALTER TABLE suspect_links DROP COLUMN link_id;
+ALTER TABLE suspect_links ADD CONSTRAINT suspect_links_id_fkey
+    FOREIGN KEY (id) REFERENCES links(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+ALTER TABLE suspect_links DROP CONSTRAINT IF EXISTS suspect_links_id_fkey;
+ALTER TABLE suspect_links ADD COLUMN link_id INTEGER;
+UPDATE suspect_links SET link_id = id;
+ALTER TABLE suspect_links ALTER COLUMN link_id SET NOT NULL;
+ALTER TABLE suspect_links ADD CONSTRAINT suspect_links_link_id_fkey
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE;
+SELECT setval('suspect_links_id_seq', COALESCE((SELECT MAX(id) FROM suspect_links), 0) + 1, false);
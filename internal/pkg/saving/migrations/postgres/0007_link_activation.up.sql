@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN activates_at TIMESTAMP;
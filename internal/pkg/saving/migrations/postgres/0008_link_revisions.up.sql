@@ -0,0 +1,18 @@
+CREATE TABLE link_revisions (
+    id SERIAL PRIMARY KEY,
+    link_id INTEGER NOT NULL,
+    original_url TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+CREATE INDEX link_revisions_link_id_idx ON link_revisions (link_id);
+
+INSERT INTO link_revisions (link_id, original_url, created_at)
+SELECT id, original_url, COALESCE(created_at, CURRENT_TIMESTAMP) FROM links;
+
+ALTER TABLE links ADD COLUMN revision_id INTEGER;
+UPDATE links SET revision_id = (SELECT MAX(r.id) FROM link_revisions r WHERE r.link_id = links.id);
+
+ALTER TABLE clicks ADD COLUMN revision_id INTEGER REFERENCES link_revisions(id) ON DELETE SET NULL;
+UPDATE clicks SET revision_id = (SELECT l.revision_id FROM links l WHERE l.id = clicks.link_id);
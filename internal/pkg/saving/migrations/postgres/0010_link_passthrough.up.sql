@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN passthrough BOOLEAN;
@@ -0,0 +1 @@
+DROP TABLE utm_presets;
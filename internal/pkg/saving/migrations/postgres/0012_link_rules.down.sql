@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN matched_rule;
+DROP TABLE link_rules;
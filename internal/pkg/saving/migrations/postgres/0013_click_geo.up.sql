@@ -0,0 +1,2 @@
+ALTER TABLE clicks ADD COLUMN country VARCHAR(2);
+ALTER TABLE clicks ADD COLUMN city VARCHAR(128);
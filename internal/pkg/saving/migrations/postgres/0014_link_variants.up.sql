@@ -0,0 +1,12 @@
+CREATE TABLE link_variants (
+    id SERIAL PRIMARY KEY,
+    link_id INTEGER NOT NULL,
+    target_url TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+CREATE INDEX link_variants_link_id_idx ON link_variants (link_id);
+
+ALTER TABLE clicks ADD COLUMN variant_id INTEGER REFERENCES link_variants(id) ON DELETE SET NULL;
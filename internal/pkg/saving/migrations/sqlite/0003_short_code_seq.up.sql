@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS short_code_seq (
+    value INTEGER NOT NULL
+);
+
+INSERT INTO short_code_seq (value) SELECT 238327 WHERE NOT EXISTS (SELECT 1 FROM short_code_seq);
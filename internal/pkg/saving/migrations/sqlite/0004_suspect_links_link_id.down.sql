@@ -0,0 +1,9 @@
+CREATE TABLE suspect_links_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    short_url VARCHAR(255) UNIQUE NOT NULL,
+    FOREIGN KEY (id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+INSERT INTO suspect_links_old (id, short_url) SELECT link_id, short_url FROM suspect_links;
+DROP TABLE suspect_links;
+ALTER TABLE suspect_links_old RENAME TO suspect_links;
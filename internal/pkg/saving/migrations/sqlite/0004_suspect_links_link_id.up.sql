@@ -0,0 +1,10 @@
+CREATE TABLE suspect_links_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id INTEGER NOT NULL,
+    short_url VARCHAR(255) UNIQUE NOT NULL,
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+INSERT INTO suspect_links_new (link_id, short_url) SELECT id, short_url FROM suspect_links;
+DROP TABLE suspect_links;
+ALTER TABLE suspect_links_new RENAME TO suspect_links;
@@ -0,0 +1,2 @@
+ALTER TABLE links ADD COLUMN max_clicks INTEGER;
+ALTER TABLE links ADD COLUMN clicks_left INTEGER;
@@ -0,0 +1,3 @@
+ALTER TABLE clicks DROP COLUMN revision_id;
+ALTER TABLE links DROP COLUMN revision_id;
+DROP TABLE link_revisions;
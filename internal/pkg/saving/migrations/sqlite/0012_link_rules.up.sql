@@ -0,0 +1,12 @@
+CREATE TABLE link_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    link_id INTEGER NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    match_value VARCHAR(64) NOT NULL,
+    target_url TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (link_id, kind, match_value),
+    FOREIGN KEY (link_id) REFERENCES links(id) ON DELETE CASCADE
+);
+
+ALTER TABLE clicks ADD COLUMN matched_rule VARCHAR(96);
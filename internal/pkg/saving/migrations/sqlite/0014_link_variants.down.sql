@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN variant_id;
+DROP TABLE link_variants;
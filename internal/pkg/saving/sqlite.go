@@ -0,0 +1,42 @@
+package saving
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	querySQLiteNextCode = `UPDATE short_code_seq SET value = value + 1 RETURNING value`
+)
+
+func CreateSQLite(path string) (*DB, error) {
+	return CreateDB(DriverSQLite, path)
+}
+
+func connectSQLite(path string) (*DB, error) {
+	conn := path
+	if strings.Contains(conn, "?") {
+		conn += "&" + sqlitePragmas
+	} else {
+		conn += "?" + sqlitePragmas
+	}
+
+	db, err := sql.Open(DriverSQLite, conn)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening SQLite database: %w", err)
+	}
+
+	return &DB{db: db, driver: DriverSQLite, queryNextCode: querySQLiteNextCode, isUniqueViolation: isSQLiteUniqueViolation}, nil
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,264 @@
+package saving
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+var (
+	ErrLinkNotFound      = errors.New("Link not found")
+	ErrAPIKeyNotFound    = errors.New("API key not found")
+	ErrShortURLTaken     = errors.New("Short link is already taken")
+	ErrUTMPresetNotFound = errors.New("UTM preset not found")
+	ErrRuleNotFound      = errors.New("Link rule not found")
+	ErrVariantNotFound   = errors.New("Link variant not found")
+)
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*Memory)(nil)
+	_ Store = (*Cache)(nil)
+)
+
+type Store interface {
+	UserInBase(id int64) bool
+	AddUser(id int64) error
+
+	SaveLink(link NewLink) error
+	LinkInBase(link string) bool
+	FindLink(link string) (int, error)
+	ShowMyLinks(id int64) ([]Link, error)
+	GetUserLink(userID int64, shortURL string) (Link, error)
+	GetOriginalURL(shortLink string) (Link, error)
+	UpdateLinkExpiry(userID int64, shortURL string, newExpiry time.Time) error
+	SetLinkPassword(userID int64, shortURL string, hash string) error
+	SetClickBudget(userID int64, shortURL string, maxClicks int) error
+	SetLinkActivation(userID int64, shortURL string, activatesAt time.Time) error
+	SetRedirectStatus(userID int64, shortURL string, status int) error
+	SetPassthrough(userID int64, shortURL string, enabled bool) error
+	SetLinkRule(userID int64, shortURL string, rule LinkRule) error
+	DeleteLinkRule(userID int64, shortURL string, kind, match string) error
+	GetLinkRules(linkID int) ([]LinkRule, error)
+	AddLinkVariant(userID int64, shortURL string, variant LinkVariant) error
+	DeleteLinkVariant(userID int64, shortURL string, variantID int) error
+	GetLinkVariants(linkID int) ([]LinkVariant, error)
+	UpdateLinkURL(userID int64, shortURL string, newURL string) error
+	UpdateLink(userID int64, shortURL string, update LinkUpdate) error
+	GetLinkRevisions(userID int64, shortURL string) ([]LinkRevision, error)
+	ConsumeClick(linkID int) (bool, error)
+	DeleteLink(shortCode string) error
+	DeleteUserLink(userID int64, shortURL string) error
+	NextCodeSequence() (int64, error)
+
+	SaveClick(click Click) error
+	SaveClicks(clicks []Click) error
+	GetClicksByUser(userID int64) (map[string]LinkClicks, error)
+	GetGeoClicks(userID int64, shortURL string) ([]GeoClicks, error)
+	GetVariantClicks(userID int64, shortURL string) ([]LinkVariant, error)
+
+	SuspectLink(id int, link string) error
+	GetSuspectLinks() ([]Link, error)
+	DeleteSuspectLink(link string) error
+
+	SaveReview(ans string, id int64) error
+	GetReviews() ([]string, error)
+
+	SaveFeedback(ans int, id int64) error
+	GetGrade() (float32, error)
+
+	GetSummaryStatistics() (Statistics, error)
+
+	SaveAPIKey(userID int64, prefix, hash, scope string) error
+	GetAPIKey(prefix string) (APIKey, error)
+	ListAPIKeys(userID int64) ([]APIKey, error)
+	DeleteAPIKey(userID int64, prefix string) error
+	TouchAPIKey(prefix string) error
+
+	SaveUTMPreset(userID int64, preset UTMPreset) error
+	GetUTMPreset(userID int64, name string) (UTMPreset, error)
+	ListUTMPresets(userID int64) ([]UTMPreset, error)
+	DeleteUTMPreset(userID int64, name string) error
+
+	Ping() error
+	Close() error
+}
+
+type Link struct {
+	ID             int
+	ShortURL       string
+	OriginalURL    string
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	PasswordHash   string
+	MaxClicks      int
+	ClicksLeft     int
+	ActivatesAt    time.Time
+	RevisionID     int
+	RedirectStatus int
+	Passthrough    bool
+}
+
+func (l Link) Exhausted() bool {
+	return l.MaxClicks > 0 && l.ClicksLeft <= 0
+}
+
+func (l Link) Pending(now time.Time) bool {
+	return !l.ActivatesAt.IsZero() && now.Before(l.ActivatesAt)
+}
+
+// NewLink is a link with everything it is created with. The link is saved
+// at once, so it never redirects before its settings are in place.
+type NewLink struct {
+	UserID         int64
+	ShortURL       string
+	OriginalURL    string
+	ExpiresAt      time.Time
+	ActivatesAt    time.Time
+	PasswordHash   string
+	MaxClicks      int
+	RedirectStatus int
+	Passthrough    bool
+	Rules          []LinkRule
+	Variants       []LinkVariant
+}
+
+// LinkUpdate is a set of changes to a link; nil fields are left as they are.
+// All of them are applied at once, so a failed update changes nothing.
+// A zero ActivatesAt clears the activation, Rules and Variants replace the
+// link's current ones.
+type LinkUpdate struct {
+	OriginalURL    *string
+	ExpiresAt      *time.Time
+	ActivatesAt    *time.Time
+	PasswordHash   *string
+	MaxClicks      *int
+	RedirectStatus *int
+	Passthrough    *bool
+	Rules          *[]LinkRule
+	Variants       *[]LinkVariant
+}
+
+type LinkRevision struct {
+	ID          int
+	OriginalURL string
+	CreatedAt   time.Time
+	Clicks      int
+}
+
+type LinkRule struct {
+	Kind      string
+	Match     string
+	TargetURL string
+}
+
+func (r LinkRule) String() string {
+	return r.Kind + ":" + r.Match
+}
+
+type LinkVariant struct {
+	ID        int
+	TargetURL string
+	Weight    int
+	Clicks    int
+}
+
+// diffVariants returns the existing variants missing from wanted and the wanted
+// ones to add. Variants with an unchanged destination and weight are kept, so
+// visitors already pinned to them by cookie stay where they are.
+func diffVariants(existing, wanted []LinkVariant) (stale, added []LinkVariant) {
+	count := make(map[LinkVariant]int)
+	for _, variant := range wanted {
+		count[LinkVariant{TargetURL: variant.TargetURL, Weight: variant.Weight}]++
+	}
+
+	for _, variant := range existing {
+		key := LinkVariant{TargetURL: variant.TargetURL, Weight: variant.Weight}
+		if count[key] > 0 {
+			count[key]--
+			continue
+		}
+		stale = append(stale, variant)
+	}
+
+	for _, variant := range wanted {
+		key := LinkVariant{TargetURL: variant.TargetURL, Weight: variant.Weight}
+		if count[key] > 0 {
+			count[key]--
+			added = append(added, key)
+		}
+	}
+
+	return stale, added
+}
+
+type Click struct {
+	LinkID      int
+	RevisionID  int
+	VariantID   int
+	IPAddress   string
+	UserAgent   string
+	MatchedRule string
+	Country     string
+	City        string
+}
+
+type GeoClicks struct {
+	Country string
+	City    string
+	Clicks  int
+}
+
+type LinkClicks struct {
+	OriginalURL string
+	Clicks      int
+}
+
+type Statistics struct {
+	Users        int
+	Links        int
+	Clicks       int
+	ExpiredLinks int
+}
+
+type APIKey struct {
+	Prefix     string
+	UserID     int64
+	Hash       string
+	Scope      string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+}
+
+type UTM struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Term     string
+	Content  string
+}
+
+type UTMPreset struct {
+	Name string
+	UTM
+}
+
+func Open(driver string, conn string) (Store, error) {
+	switch driver {
+	case DriverPostgres:
+		return CreateDB(driver, conn)
+	case DriverSQLite:
+		return CreateSQLite(conn)
+	case DriverMemory:
+		return NewMemory(), nil
+	}
+
+	return nil, fmt.Errorf("Unknown database driver %q", driver)
+}
@@ -0,0 +1,565 @@
+package saving
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	owner    int64 = 1
+	stranger int64 = 2
+)
+
+// forEachStore runs the same test against every Store implementation that
+// works without an external server.
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemory())
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		test(t, NewCache(NewMemory(), 0, 0))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		db, err := CreateSQLite(filepath.Join(t.TempDir(), "links.db"))
+		if err != nil {
+			t.Fatalf("CreateSQLite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, db)
+	})
+}
+
+// newLink adds both users and a link of the owner to the store.
+func newLink(t *testing.T, store Store, short string) Link {
+	t.Helper()
+
+	for _, id := range []int64{owner, stranger} {
+		if !store.UserInBase(id) {
+			if err := store.AddUser(id); err != nil {
+				t.Fatalf("AddUser(%d): %v", id, err)
+			}
+		}
+	}
+
+	if err := store.SaveLink(NewLink{UserID: owner, ShortURL: short, OriginalURL: "https://example.com/" + short, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveLink(%s): %v", short, err)
+	}
+
+	link, err := store.GetOriginalURL(short)
+	if err != nil {
+		t.Fatalf("GetOriginalURL(%s): %v", short, err)
+	}
+
+	return link
+}
+
+func TestLinks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+		if link.OriginalURL != "https://example.com/abc" || link.ShortURL != "abc" {
+			t.Fatalf("GetOriginalURL = %+v", link)
+		}
+
+		if !store.LinkInBase("abc") || store.LinkInBase("missing") {
+			t.Error("LinkInBase does not match the saved links")
+		}
+
+		if err := store.SaveLink(NewLink{UserID: stranger, ShortURL: "abc", OriginalURL: "https://example.org", ExpiresAt: time.Now().Add(time.Hour)}); !errors.Is(err, ErrShortURLTaken) {
+			t.Errorf("SaveLink with a taken code = %v, want ErrShortURLTaken", err)
+		}
+
+		if _, err := store.GetUserLink(stranger, "abc"); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("GetUserLink of another user = %v, want ErrLinkNotFound", err)
+		}
+
+		links, err := store.ShowMyLinks(owner)
+		if err != nil || len(links) != 1 || links[0].ShortURL != "abc" {
+			t.Errorf("ShowMyLinks = %v, %v", links, err)
+		}
+
+		if err := store.DeleteUserLink(stranger, "abc"); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("DeleteUserLink of another user = %v, want ErrLinkNotFound", err)
+		}
+
+		if err := store.DeleteUserLink(owner, "abc"); err != nil {
+			t.Fatalf("DeleteUserLink: %v", err)
+		}
+
+		if _, err := store.GetOriginalURL("abc"); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("GetOriginalURL after delete = %v, want ErrLinkNotFound", err)
+		}
+	})
+}
+
+func TestSaveLinkWithSettings(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		if err := store.AddUser(owner); err != nil {
+			t.Fatalf("AddUser: %v", err)
+		}
+
+		activatesAt := time.Now().Add(time.Minute).Truncate(time.Second)
+		rule := LinkRule{Kind: "country", Match: "RU", TargetURL: "https://example.ru"}
+		err := store.SaveLink(NewLink{
+			UserID:         owner,
+			ShortURL:       "abc",
+			OriginalURL:    "https://example.com",
+			ExpiresAt:      time.Now().Add(time.Hour),
+			ActivatesAt:    activatesAt,
+			PasswordHash:   "hash",
+			MaxClicks:      5,
+			RedirectStatus: 308,
+			Passthrough:    true,
+			Rules:          []LinkRule{rule},
+			Variants:       []LinkVariant{{TargetURL: "https://a.example", Weight: 3}},
+		})
+		if err != nil {
+			t.Fatalf("SaveLink: %v", err)
+		}
+
+		link, err := store.GetOriginalURL("abc")
+		if err != nil {
+			t.Fatalf("GetOriginalURL: %v", err)
+		}
+
+		if link.PasswordHash != "hash" || link.MaxClicks != 5 || link.ClicksLeft != 5 || link.RedirectStatus != 308 ||
+			!link.Passthrough || !link.ActivatesAt.Equal(activatesAt) || link.RevisionID == 0 {
+			t.Errorf("settings were not saved with the link: %+v", link)
+		}
+
+		if rules, err := store.GetLinkRules(link.ID); err != nil || len(rules) != 1 || rules[0] != rule {
+			t.Errorf("GetLinkRules = %v, %v", rules, err)
+		}
+
+		variants, err := store.GetLinkVariants(link.ID)
+		if err != nil || len(variants) != 1 || variants[0].TargetURL != "https://a.example" || variants[0].Weight != 3 {
+			t.Errorf("GetLinkVariants = %v, %v", variants, err)
+		}
+	})
+}
+
+func TestLinkSettings(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		newLink(t, store, "abc")
+		activatesAt := time.Now().Add(time.Minute).Truncate(time.Second)
+
+		setters := map[string]func(userID int64) error{
+			"UpdateLinkExpiry": func(userID int64) error {
+				return store.UpdateLinkExpiry(userID, "abc", time.Now().Add(48*time.Hour))
+			},
+			"SetLinkPassword":   func(userID int64) error { return store.SetLinkPassword(userID, "abc", "hash") },
+			"SetClickBudget":    func(userID int64) error { return store.SetClickBudget(userID, "abc", 2) },
+			"SetLinkActivation": func(userID int64) error { return store.SetLinkActivation(userID, "abc", activatesAt) },
+			"SetRedirectStatus": func(userID int64) error { return store.SetRedirectStatus(userID, "abc", 301) },
+			"SetPassthrough":    func(userID int64) error { return store.SetPassthrough(userID, "abc", true) },
+		}
+
+		for name, set := range setters {
+			if err := set(stranger); !errors.Is(err, ErrLinkNotFound) {
+				t.Errorf("%s of another user = %v, want ErrLinkNotFound", name, err)
+			}
+			if err := set(owner); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+
+		link, err := store.GetOriginalURL("abc")
+		if err != nil {
+			t.Fatalf("GetOriginalURL: %v", err)
+		}
+
+		if link.PasswordHash != "hash" || link.MaxClicks != 2 || link.ClicksLeft != 2 ||
+			link.RedirectStatus != 301 || !link.Passthrough || !link.ActivatesAt.Equal(activatesAt) {
+			t.Errorf("settings were not saved: %+v", link)
+		}
+
+		if link.ExpiresAt.Before(time.Now().Add(47 * time.Hour)) {
+			t.Errorf("ExpiresAt = %v, want about two days from now", link.ExpiresAt)
+		}
+
+		for i, want := range []bool{true, true, false} {
+			if ok, err := store.ConsumeClick(link.ID); err != nil || ok != want {
+				t.Errorf("ConsumeClick #%d = %v, %v, want %v", i+1, ok, err, want)
+			}
+		}
+	})
+}
+
+func TestUpdateLink(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+		if err := store.SetLinkActivation(owner, "abc", time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("SetLinkActivation: %v", err)
+		}
+		if err := store.SetLinkRule(owner, "abc", LinkRule{Kind: "device", Match: "ios", TargetURL: "https://apple.com"}); err != nil {
+			t.Fatalf("SetLinkRule: %v", err)
+		}
+		for _, target := range []string{"https://a.example", "https://b.example"} {
+			if err := store.AddLinkVariant(owner, "abc", LinkVariant{TargetURL: target, Weight: 1}); err != nil {
+				t.Fatalf("AddLinkVariant: %v", err)
+			}
+		}
+		before, err := store.GetLinkVariants(link.ID)
+		if err != nil {
+			t.Fatalf("GetLinkVariants: %v", err)
+		}
+
+		url, hash, budget, status, passthrough := "https://example.org", "hash", 3, 308, true
+		expiresAt := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+		var activatesAt time.Time
+		rules := []LinkRule{{Kind: "device", Match: "android", TargetURL: "https://play.google.com"}}
+		variants := []LinkVariant{{TargetURL: "https://a.example", Weight: 1}, {TargetURL: "https://c.example", Weight: 2}}
+		update := LinkUpdate{
+			OriginalURL:    &url,
+			ExpiresAt:      &expiresAt,
+			ActivatesAt:    &activatesAt,
+			PasswordHash:   &hash,
+			MaxClicks:      &budget,
+			RedirectStatus: &status,
+			Passthrough:    &passthrough,
+			Rules:          &rules,
+			Variants:       &variants,
+		}
+
+		if err := store.UpdateLink(stranger, "abc", update); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("UpdateLink of another user = %v, want ErrLinkNotFound", err)
+		}
+		if err := store.UpdateLink(owner, "abc", update); err != nil {
+			t.Fatalf("UpdateLink: %v", err)
+		}
+
+		link, err = store.GetOriginalURL("abc")
+		if err != nil {
+			t.Fatalf("GetOriginalURL: %v", err)
+		}
+
+		if link.OriginalURL != url || !link.ExpiresAt.Equal(expiresAt) || !link.ActivatesAt.IsZero() ||
+			link.PasswordHash != hash || link.MaxClicks != budget || link.ClicksLeft != budget ||
+			link.RedirectStatus != status || !link.Passthrough {
+			t.Errorf("update was not applied: %+v", link)
+		}
+
+		if got, err := store.GetLinkRules(link.ID); err != nil || len(got) != 1 || got[0] != rules[0] {
+			t.Errorf("GetLinkRules = %+v, %v, want %+v", got, err, rules)
+		}
+
+		got, err := store.GetLinkVariants(link.ID)
+		if err != nil || len(got) != 2 || got[0].ID != before[0].ID ||
+			got[1].TargetURL != "https://c.example" || got[1].Weight != 2 {
+			t.Errorf("GetLinkVariants = %+v, %v; want the first variant kept and c.example added", got, err)
+		}
+
+		if err := store.UpdateLink(owner, "abc", LinkUpdate{}); err != nil {
+			t.Errorf("empty UpdateLink: %v", err)
+		}
+		if unchanged, _ := store.GetOriginalURL("abc"); unchanged != link {
+			t.Errorf("empty UpdateLink changed the link: %+v, was %+v", unchanged, link)
+		}
+	})
+}
+
+func TestClickBudgetKeepsUsedClicks(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+		budget := func(maxClicks, wantLeft int) {
+			t.Helper()
+			if err := store.SetClickBudget(owner, "abc", maxClicks); err != nil {
+				t.Fatalf("SetClickBudget(%d): %v", maxClicks, err)
+			}
+			if link, err := store.GetUserLink(owner, "abc"); err != nil || link.MaxClicks != maxClicks || link.ClicksLeft != wantLeft {
+				t.Errorf("after SetClickBudget(%d) = %d of %d, %v; want %d left", maxClicks, link.ClicksLeft, link.MaxClicks, err, wantLeft)
+			}
+		}
+
+		budget(5, 5)
+		for range 3 {
+			if ok, err := store.ConsumeClick(link.ID); !ok || err != nil {
+				t.Fatalf("ConsumeClick = %v, %v", ok, err)
+			}
+		}
+
+		budget(10, 7)
+		budget(2, 0)
+		if ok, _ := store.ConsumeClick(link.ID); ok {
+			t.Error("ConsumeClick succeeded after the budget was lowered below the used clicks")
+		}
+		budget(4, 2)
+		budget(0, 0)
+		budget(3, 3)
+	})
+}
+
+func TestLinkTimesKeepTimeZone(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		newLink(t, store, "abc")
+		msk := time.FixedZone("MSK", 3*60*60)
+
+		expiresAt := time.Now().Add(-time.Hour).Truncate(time.Second).In(msk)
+		activatesAt := expiresAt.Add(-time.Hour)
+		if err := store.UpdateLinkExpiry(owner, "abc", expiresAt); err != nil {
+			t.Fatalf("UpdateLinkExpiry: %v", err)
+		}
+		if err := store.SetLinkActivation(owner, "abc", activatesAt); err != nil {
+			t.Fatalf("SetLinkActivation: %v", err)
+		}
+
+		link, err := store.GetOriginalURL("abc")
+		if err != nil {
+			t.Fatalf("GetOriginalURL: %v", err)
+		}
+
+		if !link.ExpiresAt.Equal(expiresAt) || !link.ActivatesAt.Equal(activatesAt) {
+			t.Errorf("times moved: expires %v, want %v; activates %v, want %v",
+				link.ExpiresAt, expiresAt, link.ActivatesAt, activatesAt)
+		}
+
+		if stats, err := store.GetSummaryStatistics(); err != nil || stats.ExpiredLinks != 1 {
+			t.Errorf("GetSummaryStatistics = %+v, %v, want 1 expired link", stats, err)
+		}
+	})
+}
+
+func TestLinkRevisions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+		if err := store.SaveClick(Click{LinkID: link.ID, RevisionID: link.RevisionID}); err != nil {
+			t.Fatalf("SaveClick: %v", err)
+		}
+
+		if err := store.UpdateLinkURL(stranger, "abc", "https://example.org"); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("UpdateLinkURL of another user = %v, want ErrLinkNotFound", err)
+		}
+
+		if err := store.UpdateLinkURL(owner, "abc", "https://example.org"); err != nil {
+			t.Fatalf("UpdateLinkURL: %v", err)
+		}
+
+		revisions, err := store.GetLinkRevisions(owner, "abc")
+		if err != nil {
+			t.Fatalf("GetLinkRevisions: %v", err)
+		}
+
+		if len(revisions) != 2 || revisions[0].OriginalURL != "https://example.org" || revisions[0].Clicks != 0 ||
+			revisions[1].OriginalURL != "https://example.com/abc" || revisions[1].Clicks != 1 {
+			t.Errorf("GetLinkRevisions = %+v", revisions)
+		}
+
+		if _, err := store.GetLinkRevisions(stranger, "abc"); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("GetLinkRevisions of another user = %v, want ErrLinkNotFound", err)
+		}
+	})
+}
+
+func TestLinkRules(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+
+		rule := LinkRule{Kind: "country", Match: "RU", TargetURL: "https://example.ru"}
+		if err := store.SetLinkRule(stranger, "abc", rule); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("SetLinkRule of another user = %v, want ErrLinkNotFound", err)
+		}
+
+		if err := store.SetLinkRule(owner, "abc", rule); err != nil {
+			t.Fatalf("SetLinkRule: %v", err)
+		}
+
+		rule.TargetURL = "https://example.ru/new"
+		if err := store.SetLinkRule(owner, "abc", rule); err != nil {
+			t.Fatalf("SetLinkRule for an existing rule: %v", err)
+		}
+
+		rules, err := store.GetLinkRules(link.ID)
+		if err != nil || len(rules) != 1 || rules[0] != rule {
+			t.Errorf("GetLinkRules = %v, %v, want only %v", rules, err, rule)
+		}
+
+		if err := store.DeleteLinkRule(stranger, "abc", rule.Kind, rule.Match); !errors.Is(err, ErrRuleNotFound) {
+			t.Errorf("DeleteLinkRule of another user = %v, want ErrRuleNotFound", err)
+		}
+
+		if err := store.DeleteLinkRule(owner, "abc", rule.Kind, rule.Match); err != nil {
+			t.Fatalf("DeleteLinkRule: %v", err)
+		}
+
+		if rules, err := store.GetLinkRules(link.ID); err != nil || len(rules) != 0 {
+			t.Errorf("GetLinkRules after delete = %v, %v", rules, err)
+		}
+	})
+}
+
+func TestLinkVariants(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+
+		if err := store.AddLinkVariant(stranger, "abc", LinkVariant{TargetURL: "https://a.example", Weight: 1}); !errors.Is(err, ErrLinkNotFound) {
+			t.Errorf("AddLinkVariant of another user = %v, want ErrLinkNotFound", err)
+		}
+
+		for _, target := range []string{"https://a.example", "https://b.example"} {
+			if err := store.AddLinkVariant(owner, "abc", LinkVariant{TargetURL: target, Weight: 1}); err != nil {
+				t.Fatalf("AddLinkVariant: %v", err)
+			}
+		}
+
+		variants, err := store.GetLinkVariants(link.ID)
+		if err != nil || len(variants) != 2 {
+			t.Fatalf("GetLinkVariants = %v, %v", variants, err)
+		}
+
+		clicks := []Click{
+			{LinkID: link.ID, VariantID: variants[0].ID},
+			{LinkID: link.ID, VariantID: variants[1].ID},
+			{LinkID: link.ID, VariantID: variants[1].ID},
+		}
+		if err := store.SaveClicks(clicks); err != nil {
+			t.Fatalf("SaveClicks: %v", err)
+		}
+
+		if err := store.DeleteLinkVariant(stranger, "abc", variants[1].ID); !errors.Is(err, ErrVariantNotFound) {
+			t.Errorf("DeleteLinkVariant of another user = %v, want ErrVariantNotFound", err)
+		}
+
+		if err := store.DeleteLinkVariant(owner, "abc", variants[1].ID); err != nil {
+			t.Fatalf("DeleteLinkVariant: %v", err)
+		}
+
+		stats, err := store.GetVariantClicks(owner, "abc")
+		if err != nil || len(stats) != 1 || stats[0].ID != variants[0].ID || stats[0].Clicks != 1 {
+			t.Errorf("GetVariantClicks = %+v, %v", stats, err)
+		}
+
+		// The clicks of a deleted variant still count for the link.
+		byUser, err := store.GetClicksByUser(owner)
+		if err != nil || byUser["abc"].Clicks != 3 {
+			t.Errorf("GetClicksByUser = %v, %v, want 3 clicks", byUser, err)
+		}
+
+		if err := store.AddLinkVariant(owner, "abc", LinkVariant{TargetURL: "https://c.example", Weight: 1}); err != nil {
+			t.Fatalf("AddLinkVariant: %v", err)
+		}
+
+		stats, err = store.GetVariantClicks(owner, "abc")
+		if err != nil || len(stats) != 2 || stats[1].Clicks != 0 {
+			t.Errorf("a new variant inherited clicks: %+v, %v", stats, err)
+		}
+	})
+}
+
+func TestClickStats(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		link := newLink(t, store, "abc")
+		newLink(t, store, "def")
+
+		clicks := []Click{
+			{LinkID: link.ID, Country: "RU", City: "Moscow"},
+			{LinkID: link.ID, Country: "RU", City: "Moscow"},
+			{LinkID: link.ID, Country: "DE", City: "Berlin"},
+			{LinkID: link.ID},
+		}
+		if err := store.SaveClicks(clicks); err != nil {
+			t.Fatalf("SaveClicks: %v", err)
+		}
+
+		byUser, err := store.GetClicksByUser(owner)
+		if err != nil || len(byUser) != 2 || byUser["abc"].Clicks != 4 || byUser["def"].Clicks != 0 {
+			t.Errorf("GetClicksByUser = %v, %v", byUser, err)
+		}
+
+		geo, err := store.GetGeoClicks(owner, "abc")
+		if err != nil || len(geo) != 3 || geo[0] != (GeoClicks{Country: "RU", City: "Moscow", Clicks: 2}) {
+			t.Errorf("GetGeoClicks = %+v, %v", geo, err)
+		}
+
+		if geo, err := store.GetGeoClicks(stranger, "abc"); err != nil || len(geo) != 0 {
+			t.Errorf("GetGeoClicks of another user = %+v, %v, want nothing", geo, err)
+		}
+
+		stats, err := store.GetSummaryStatistics()
+		if err != nil || stats != (Statistics{Users: 2, Links: 2, Clicks: 4}) {
+			t.Errorf("GetSummaryStatistics = %+v, %v", stats, err)
+		}
+
+		if err := store.DeleteLink("abc"); err != nil {
+			t.Fatalf("DeleteLink: %v", err)
+		}
+
+		if stats, err := store.GetSummaryStatistics(); err != nil || stats.Clicks != 0 {
+			t.Errorf("clicks of a deleted link are still counted: %+v, %v", stats, err)
+		}
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		newLink(t, store, "abc")
+
+		if err := store.SaveAPIKey(owner, "prefix", "hash", "read"); err != nil {
+			t.Fatalf("SaveAPIKey: %v", err)
+		}
+
+		key, err := store.GetAPIKey("prefix")
+		if err != nil || key.UserID != owner || key.Hash != "hash" || key.Scope != "read" || key.LastUsedAt.Valid {
+			t.Errorf("GetAPIKey = %+v, %v", key, err)
+		}
+
+		if err := store.TouchAPIKey("prefix"); err != nil {
+			t.Fatalf("TouchAPIKey: %v", err)
+		}
+
+		keys, err := store.ListAPIKeys(owner)
+		if err != nil || len(keys) != 1 || !keys[0].LastUsedAt.Valid {
+			t.Errorf("ListAPIKeys = %+v, %v", keys, err)
+		}
+
+		if err := store.DeleteAPIKey(stranger, "prefix"); !errors.Is(err, ErrAPIKeyNotFound) {
+			t.Errorf("DeleteAPIKey of another user = %v, want ErrAPIKeyNotFound", err)
+		}
+
+		if err := store.DeleteAPIKey(owner, "prefix"); err != nil {
+			t.Fatalf("DeleteAPIKey: %v", err)
+		}
+
+		if _, err := store.GetAPIKey("prefix"); !errors.Is(err, ErrAPIKeyNotFound) {
+			t.Errorf("GetAPIKey after delete = %v, want ErrAPIKeyNotFound", err)
+		}
+	})
+}
+
+func TestUTMPresets(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		newLink(t, store, "abc")
+
+		preset := UTMPreset{Name: "news", UTM: UTM{Source: "telegram", Medium: "post"}}
+		if err := store.SaveUTMPreset(owner, preset); err != nil {
+			t.Fatalf("SaveUTMPreset: %v", err)
+		}
+
+		preset.Campaign = "autumn"
+		if err := store.SaveUTMPreset(owner, preset); err != nil {
+			t.Fatalf("SaveUTMPreset for an existing preset: %v", err)
+		}
+
+		if got, err := store.GetUTMPreset(owner, "news"); err != nil || got != preset {
+			t.Errorf("GetUTMPreset = %+v, %v, want %+v", got, err, preset)
+		}
+
+		if _, err := store.GetUTMPreset(stranger, "news"); !errors.Is(err, ErrUTMPresetNotFound) {
+			t.Errorf("GetUTMPreset of another user = %v, want ErrUTMPresetNotFound", err)
+		}
+
+		if presets, err := store.ListUTMPresets(owner); err != nil || len(presets) != 1 {
+			t.Errorf("ListUTMPresets = %+v, %v", presets, err)
+		}
+
+		if err := store.DeleteUTMPreset(owner, "news"); err != nil {
+			t.Fatalf("DeleteUTMPreset: %v", err)
+		}
+
+		if err := store.DeleteUTMPreset(owner, "news"); !errors.Is(err, ErrUTMPresetNotFound) {
+			t.Errorf("DeleteUTMPreset twice = %v, want ErrUTMPresetNotFound", err)
+		}
+	})
+}
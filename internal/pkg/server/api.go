@@ -0,0 +1,738 @@
+package server
+
+import (
+	"2links/internal/pkg/apikey"
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const apiPrefix = "/api/v1"
+
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type apiLink struct {
+	Code              string       `json:"code"`
+	ShortURL          string       `json:"short_url"`
+	OriginalURL       string       `json:"original_url"`
+	CreatedAt         time.Time    `json:"created_at"`
+	ExpiresAt         time.Time    `json:"expires_at"`
+	ActivatesAt       *time.Time   `json:"activates_at"`
+	PasswordProtected bool         `json:"password_protected"`
+	MaxClicks         *int         `json:"max_clicks"`
+	ClicksLeft        *int         `json:"clicks_left"`
+	RedirectStatus    *int         `json:"redirect_status"`
+	Passthrough       bool         `json:"passthrough"`
+	Rules             []apiRule    `json:"rules"`
+	Variants          []apiVariant `json:"variants"`
+	Clicks            int          `json:"clicks"`
+}
+
+type createLinkRequest struct {
+	URL            string       `json:"url"`
+	Alias          string       `json:"alias"`
+	ExpiresAt      *time.Time   `json:"expires_at"`
+	ActivatesAt    *time.Time   `json:"activates_at"`
+	Password       string       `json:"password"`
+	MaxClicks      int          `json:"max_clicks"`
+	RedirectStatus int          `json:"redirect_status"`
+	Passthrough    bool         `json:"passthrough"`
+	UTM            *apiUTM      `json:"utm"`
+	UTMPreset      string       `json:"utm_preset"`
+	Rules          []apiRule    `json:"rules"`
+	Variants       []apiVariant `json:"variants"`
+}
+
+type apiRule struct {
+	Kind      string `json:"kind"`
+	Match     string `json:"match"`
+	TargetURL string `json:"target_url"`
+}
+
+type apiVariant struct {
+	ID        int    `json:"id,omitempty"`
+	TargetURL string `json:"target_url"`
+	Weight    int    `json:"weight"`
+}
+
+type apiUTM struct {
+	Source   string `json:"source"`
+	Medium   string `json:"medium"`
+	Campaign string `json:"campaign"`
+	Term     string `json:"term"`
+	Content  string `json:"content"`
+}
+
+type apiLinkStats struct {
+	Countries []apiGeoClicks     `json:"countries"`
+	Cities    []apiGeoClicks     `json:"cities"`
+	Variants  []apiVariantClicks `json:"variants"`
+}
+
+type apiVariantClicks struct {
+	ID        int    `json:"id"`
+	TargetURL string `json:"target_url"`
+	Weight    int    `json:"weight"`
+	Clicks    int    `json:"clicks"`
+}
+
+type apiGeoClicks struct {
+	Country string `json:"country"`
+	City    string `json:"city,omitempty"`
+	Clicks  int    `json:"clicks"`
+}
+
+type apiRevision struct {
+	ID          int       `json:"id"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	Clicks      int       `json:"clicks"`
+}
+
+type updateLinkRequest struct {
+	URL            *string       `json:"url"`
+	ExpiresAt      *time.Time    `json:"expires_at"`
+	ActivatesAt    optionalTime  `json:"activates_at"`
+	Password       *string       `json:"password"`
+	MaxClicks      *int          `json:"max_clicks"`
+	RedirectStatus *int          `json:"redirect_status"`
+	Passthrough    *bool         `json:"passthrough"`
+	Rules          *[]apiRule    `json:"rules"`
+	Variants       *[]apiVariant `json:"variants"`
+}
+
+// optionalTime tells an explicit null, which clears the time, from a missing
+// field, which leaves it as it is.
+type optionalTime struct {
+	Set  bool
+	Time time.Time
+}
+
+func (t *optionalTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	return json.Unmarshal(data, &t.Time)
+}
+
+type apiHandler func(w http.ResponseWriter, r *http.Request, userID int64)
+
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET "+apiPrefix+"/links", s.withAuth(apikey.ScopeRead, s.handleListLinks))
+	mux.HandleFunc("POST "+apiPrefix+"/links", s.withAuth(apikey.ScopeWrite, s.handleCreateLink))
+	mux.HandleFunc("GET "+apiPrefix+"/links/{code}", s.withAuth(apikey.ScopeRead, s.handleGetLink))
+	mux.HandleFunc("PATCH "+apiPrefix+"/links/{code}", s.withAuth(apikey.ScopeWrite, s.handleUpdateLink))
+	mux.HandleFunc("DELETE "+apiPrefix+"/links/{code}", s.withAuth(apikey.ScopeWrite, s.handleDeleteLink))
+	mux.HandleFunc("GET "+apiPrefix+"/links/{code}/revisions", s.withAuth(apikey.ScopeRead, s.handleListRevisions))
+	mux.HandleFunc("GET "+apiPrefix+"/links/{code}/stats", s.withAuth(apikey.ScopeRead, s.handleLinkStats))
+
+	mux.HandleFunc(apiPrefix+"/links", handleMethodNotAllowed)
+	mux.HandleFunc(apiPrefix+"/links/{code}", handleMethodNotAllowed)
+	mux.HandleFunc(apiPrefix+"/links/{code}/revisions", handleMethodNotAllowed)
+	mux.HandleFunc(apiPrefix+"/links/{code}/stats", handleMethodNotAllowed)
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "Unknown API endpoint")
+	})
+}
+
+func (s *Server) withAuth(scope string, next apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Missing bearer token")
+			return
+		}
+
+		prefix, secret, ok := apikey.Parse(bearer)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Invalid API key")
+			return
+		}
+
+		key, err := s.db.GetAPIKey(prefix)
+		if errors.Is(err, saving.ErrAPIKeyNotFound) || err == nil && !apikey.Check(secret, key.Hash) {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "Invalid API key")
+			return
+		} else if err != nil {
+			log.Printf("Error fetching API key: %v", err)
+			writeError(w, http.StatusInternalServerError, "internal", "Failed to check API key")
+			return
+		}
+
+		if !apikey.Allows(key.Scope, scope) {
+			writeError(w, http.StatusForbidden, "forbidden", "API key does not allow this operation")
+			return
+		}
+
+		if err := s.db.TouchAPIKey(prefix); err != nil {
+			log.Printf("Error updating API key usage: %v", err)
+		}
+
+		next(w, r, key.UserID)
+	}
+}
+
+func (s *Server) handleListLinks(w http.ResponseWriter, r *http.Request, userID int64) {
+	links, err := s.db.ShowMyLinks(userID)
+	if err != nil {
+		log.Printf("Error fetching links: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch links")
+		return
+	}
+
+	stats, err := s.db.GetClicksByUser(userID)
+	if err != nil {
+		log.Printf("Error fetching clicks: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch clicks")
+		return
+	}
+
+	result := make([]apiLink, 0, len(links))
+	for _, link := range links {
+		result = append(result, s.toAPILink(link, stats[link.ShortURL].Clicks))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleCreateLink(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req createLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "Request body must be a JSON object")
+		return
+	}
+
+	if !shortener.CheckValidacy(req.URL) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_url", "URL is not valid")
+		return
+	}
+
+	if req.UTM != nil || req.UTMPreset != "" {
+		utm, ok := s.resolveUTM(w, userID, req.UTMPreset, req.UTM)
+		if !ok {
+			return
+		}
+		req.URL = shortener.ApplyUTM(req.URL, utm)
+	}
+
+	if req.ExpiresAt != nil {
+		if err := s.links.CheckExpiry(*req.ExpiresAt); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_expiry", err.Error())
+			return
+		}
+	}
+
+	if req.ActivatesAt != nil {
+		expiresAt := s.links.DefaultExpiry()
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+		}
+
+		if err := shortener.CheckActivation(*req.ActivatesAt, expiresAt); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_activation", err.Error())
+			return
+		}
+	}
+
+	if req.MaxClicks < 0 {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_max_clicks", "max_clicks must not be negative")
+		return
+	}
+
+	if req.RedirectStatus != 0 && !shortener.ValidRedirectStatus(req.RedirectStatus) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_redirect_status", "redirect_status must be one of 301, 302, 307 or 308")
+		return
+	}
+
+	if !validateRules(w, req.Rules) {
+		return
+	}
+
+	if !validateVariants(w, req.Variants) {
+		return
+	}
+
+	newLink := saving.NewLink{
+		UserID:         userID,
+		ShortURL:       req.Alias,
+		OriginalURL:    req.URL,
+		MaxClicks:      req.MaxClicks,
+		RedirectStatus: req.RedirectStatus,
+		Passthrough:    req.Passthrough,
+	}
+
+	if req.ExpiresAt != nil {
+		newLink.ExpiresAt = *req.ExpiresAt
+	}
+
+	if req.ActivatesAt != nil {
+		newLink.ActivatesAt = *req.ActivatesAt
+	}
+
+	if req.Password != "" {
+		hash, ok := hashPassword(w, req.Password)
+		if !ok {
+			return
+		}
+		newLink.PasswordHash = hash
+	}
+
+	for _, rule := range req.Rules {
+		newLink.Rules = append(newLink.Rules, toLinkRule(rule))
+	}
+
+	for _, variant := range req.Variants {
+		newLink.Variants = append(newLink.Variants, toLinkVariant(variant))
+	}
+
+	code, err := s.links.CreateShortLink(newLink)
+	if errors.Is(err, shortener.ErrAliasInvalid) || errors.Is(err, shortener.ErrAliasReserved) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_alias", err.Error())
+		return
+	} else if errors.Is(err, shortener.ErrAliasTaken) {
+		writeError(w, http.StatusConflict, "alias_taken", err.Error())
+		return
+	} else if err != nil {
+		log.Printf("Error creating short link: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to create short link")
+		return
+	}
+
+	link, err := s.db.GetUserLink(userID, code)
+	if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch link")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, s.toAPILink(link, 0))
+}
+
+func (s *Server) handleGetLink(w http.ResponseWriter, r *http.Request, userID int64) {
+	link, ok := s.lookupLink(w, userID, r.PathValue("code"))
+	if !ok {
+		return
+	}
+
+	stats, err := s.db.GetClicksByUser(userID)
+	if err != nil {
+		log.Printf("Error fetching clicks: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch clicks")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.toAPILink(link, stats[link.ShortURL].Clicks))
+}
+
+func (s *Server) handleUpdateLink(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req updateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "Request body must be a JSON object")
+		return
+	}
+
+	if req.URL == nil && req.ExpiresAt == nil && !req.ActivatesAt.Set && req.Password == nil && req.MaxClicks == nil &&
+		req.RedirectStatus == nil && req.Passthrough == nil && req.Rules == nil &&
+		req.Variants == nil {
+		writeError(w, http.StatusUnprocessableEntity, "empty_update", "Nothing to update")
+		return
+	}
+
+	if req.URL != nil && !shortener.CheckValidacy(*req.URL) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_url", "URL is not valid")
+		return
+	}
+
+	if req.ExpiresAt != nil {
+		if err := s.links.CheckExpiry(*req.ExpiresAt); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_expiry", err.Error())
+			return
+		}
+	}
+
+	if req.MaxClicks != nil && *req.MaxClicks < 0 {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_max_clicks", "max_clicks must not be negative")
+		return
+	}
+
+	if req.RedirectStatus != nil && *req.RedirectStatus != 0 && !shortener.ValidRedirectStatus(*req.RedirectStatus) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_redirect_status", "redirect_status must be one of 301, 302, 307 or 308")
+		return
+	}
+
+	if req.Rules != nil && !validateRules(w, *req.Rules) {
+		return
+	}
+
+	if req.Variants != nil && !validateVariants(w, *req.Variants) {
+		return
+	}
+
+	update := saving.LinkUpdate{
+		OriginalURL:    req.URL,
+		ExpiresAt:      req.ExpiresAt,
+		MaxClicks:      req.MaxClicks,
+		RedirectStatus: req.RedirectStatus,
+		Passthrough:    req.Passthrough,
+	}
+
+	if req.ActivatesAt.Set {
+		update.ActivatesAt = &req.ActivatesAt.Time
+	}
+
+	if req.Password != nil {
+		var passwordHash string
+		if *req.Password != "" {
+			hash, ok := hashPassword(w, *req.Password)
+			if !ok {
+				return
+			}
+			passwordHash = hash
+		}
+		update.PasswordHash = &passwordHash
+	}
+
+	if req.Rules != nil {
+		rules := make([]saving.LinkRule, 0, len(*req.Rules))
+		for _, rule := range *req.Rules {
+			rules = append(rules, toLinkRule(rule))
+		}
+		update.Rules = &rules
+	}
+
+	if req.Variants != nil {
+		variants := make([]saving.LinkVariant, 0, len(*req.Variants))
+		for _, variant := range *req.Variants {
+			variants = append(variants, toLinkVariant(variant))
+		}
+		update.Variants = &variants
+	}
+
+	code := r.PathValue("code")
+	if req.ExpiresAt != nil || req.ActivatesAt.Set {
+		link, ok := s.lookupLink(w, userID, code)
+		if !ok {
+			return
+		}
+
+		expiresAt, activatesAt := link.ExpiresAt, link.ActivatesAt
+		if req.ExpiresAt != nil {
+			expiresAt = *req.ExpiresAt
+		}
+		if req.ActivatesAt.Set {
+			activatesAt = req.ActivatesAt.Time
+		}
+
+		if !activatesAt.IsZero() {
+			if err := shortener.CheckActivation(activatesAt, expiresAt); err != nil {
+				errorCode := "invalid_activation"
+				if !req.ActivatesAt.Set {
+					errorCode = "invalid_expiry"
+				}
+				writeError(w, http.StatusUnprocessableEntity, errorCode, err.Error())
+				return
+			}
+		}
+	}
+
+	err := s.db.UpdateLink(userID, code, update)
+	if errors.Is(err, saving.ErrLinkNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "Link not found")
+		return
+	} else if err != nil {
+		log.Printf("Error updating link: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to update link")
+		return
+	}
+
+	link, ok := s.lookupLink(w, userID, code)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.toAPILink(link, 0))
+}
+
+func (s *Server) handleListRevisions(w http.ResponseWriter, r *http.Request, userID int64) {
+	revisions, err := s.db.GetLinkRevisions(userID, r.PathValue("code"))
+	if errors.Is(err, saving.ErrLinkNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "Link not found")
+		return
+	} else if err != nil {
+		log.Printf("Error fetching link revisions: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch revisions")
+		return
+	}
+
+	result := make([]apiRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, apiRevision{
+			ID:          revision.ID,
+			OriginalURL: revision.OriginalURL,
+			CreatedAt:   revision.CreatedAt,
+			Clicks:      revision.Clicks,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleLinkStats(w http.ResponseWriter, r *http.Request, userID int64) {
+	link, ok := s.lookupLink(w, userID, r.PathValue("code"))
+	if !ok {
+		return
+	}
+
+	geo, err := s.db.GetGeoClicks(userID, link.ShortURL)
+	if err != nil {
+		log.Printf("Error fetching clicks by location: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch stats")
+		return
+	}
+
+	variants, err := s.db.GetVariantClicks(userID, link.ShortURL)
+	if err != nil {
+		log.Printf("Error fetching clicks by variant: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch stats")
+		return
+	}
+
+	stats := apiLinkStats{Countries: []apiGeoClicks{}, Cities: []apiGeoClicks{}, Variants: []apiVariantClicks{}}
+	for _, variant := range variants {
+		stats.Variants = append(stats.Variants, apiVariantClicks{
+			ID:        variant.ID,
+			TargetURL: variant.TargetURL,
+			Weight:    variant.Weight,
+			Clicks:    variant.Clicks,
+		})
+	}
+
+	countries := make(map[string]int)
+	for _, entry := range geo {
+		if _, ok := countries[entry.Country]; !ok {
+			countries[entry.Country] = len(stats.Countries)
+			stats.Countries = append(stats.Countries, apiGeoClicks{Country: entry.Country})
+		}
+		stats.Countries[countries[entry.Country]].Clicks += entry.Clicks
+
+		if entry.City != "" {
+			stats.Cities = append(stats.Cities, apiGeoClicks{Country: entry.Country, City: entry.City, Clicks: entry.Clicks})
+		}
+	}
+
+	sort.SliceStable(stats.Countries, func(i, j int) bool {
+		return stats.Countries[i].Clicks > stats.Countries[j].Clicks
+	})
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func (s *Server) handleDeleteLink(w http.ResponseWriter, r *http.Request, userID int64) {
+	err := s.db.DeleteUserLink(userID, r.PathValue("code"))
+	if errors.Is(err, saving.ErrLinkNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "Link not found")
+		return
+	} else if err != nil {
+		log.Printf("Error deleting link: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to delete link")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func hashPassword(w http.ResponseWriter, password string) (string, bool) {
+	hash, err := shortener.HashLinkPassword(password)
+	if errors.Is(err, shortener.ErrPasswordShort) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_password", err.Error())
+		return "", false
+	} else if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to hash password")
+		return "", false
+	}
+
+	return hash, true
+}
+
+func (s *Server) resolveUTM(w http.ResponseWriter, userID int64, presetName string, fields *apiUTM) (saving.UTM, bool) {
+	var utm saving.UTM
+	if presetName != "" {
+		preset, err := s.db.GetUTMPreset(userID, presetName)
+		if errors.Is(err, saving.ErrUTMPresetNotFound) {
+			writeError(w, http.StatusUnprocessableEntity, "unknown_utm_preset", "UTM preset not found")
+			return utm, false
+		} else if err != nil {
+			log.Printf("Error fetching UTM preset: %v", err)
+			writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch UTM preset")
+			return utm, false
+		}
+		utm = preset.UTM
+	}
+
+	if fields != nil {
+		if fields.Source != "" {
+			utm.Source = fields.Source
+		}
+		if fields.Medium != "" {
+			utm.Medium = fields.Medium
+		}
+		if fields.Campaign != "" {
+			utm.Campaign = fields.Campaign
+		}
+		if fields.Term != "" {
+			utm.Term = fields.Term
+		}
+		if fields.Content != "" {
+			utm.Content = fields.Content
+		}
+	}
+
+	if err := shortener.ValidateUTM(utm); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_utm", err.Error())
+		return utm, false
+	}
+
+	return utm, true
+}
+
+func validateRules(w http.ResponseWriter, rules []apiRule) bool {
+	for _, rule := range rules {
+		if err := shortener.ValidateRule(toLinkRule(rule)); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_rule", err.Error())
+			return false
+		}
+	}
+
+	return true
+}
+
+func toLinkRule(rule apiRule) saving.LinkRule {
+	return shortener.NormalizeRule(saving.LinkRule{Kind: rule.Kind, Match: rule.Match, TargetURL: rule.TargetURL})
+}
+
+func validateVariants(w http.ResponseWriter, variants []apiVariant) bool {
+	if len(variants) > shortener.MaxVariants {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_variant", fmt.Sprintf("A link may have at most %d variants", shortener.MaxVariants))
+		return false
+	}
+
+	for _, variant := range variants {
+		if err := shortener.ValidateVariant(toLinkVariant(variant)); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid_variant", err.Error())
+			return false
+		}
+	}
+
+	return true
+}
+
+func toLinkVariant(variant apiVariant) saving.LinkVariant {
+	weight := variant.Weight
+	if weight == 0 {
+		weight = 1
+	}
+
+	return saving.LinkVariant{TargetURL: strings.TrimSpace(variant.TargetURL), Weight: weight}
+}
+
+func (s *Server) lookupLink(w http.ResponseWriter, userID int64, code string) (saving.Link, bool) {
+	link, err := s.db.GetUserLink(userID, code)
+	if errors.Is(err, saving.ErrLinkNotFound) {
+		writeError(w, http.StatusNotFound, "not_found", "Link not found")
+		return link, false
+	} else if err != nil {
+		log.Printf("Error fetching link: %v", err)
+		writeError(w, http.StatusInternalServerError, "internal", "Failed to fetch link")
+		return link, false
+	}
+
+	return link, true
+}
+
+func (s *Server) toAPILink(link saving.Link, clicks int) apiLink {
+	var maxClicks, clicksLeft *int
+	if link.MaxClicks > 0 {
+		maxClicks, clicksLeft = &link.MaxClicks, &link.ClicksLeft
+	}
+
+	rules, err := s.db.GetLinkRules(link.ID)
+	if err != nil {
+		log.Printf("Error fetching link rules: %v", err)
+	}
+
+	apiRules := make([]apiRule, 0, len(rules))
+	for _, rule := range rules {
+		apiRules = append(apiRules, apiRule{Kind: rule.Kind, Match: rule.Match, TargetURL: rule.TargetURL})
+	}
+
+	variants, err := s.db.GetLinkVariants(link.ID)
+	if err != nil {
+		log.Printf("Error fetching link variants: %v", err)
+	}
+
+	apiVariants := make([]apiVariant, 0, len(variants))
+	for _, variant := range variants {
+		apiVariants = append(apiVariants, apiVariant{ID: variant.ID, TargetURL: variant.TargetURL, Weight: variant.Weight})
+	}
+
+	var redirectStatus *int
+	if link.RedirectStatus != 0 {
+		redirectStatus = &link.RedirectStatus
+	}
+
+	var activatesAt *time.Time
+	if !link.ActivatesAt.IsZero() {
+		activatesAt = &link.ActivatesAt
+	}
+
+	return apiLink{
+		Code:              link.ShortURL,
+		ShortURL:          s.url + link.ShortURL,
+		OriginalURL:       link.OriginalURL,
+		CreatedAt:         link.CreatedAt,
+		ExpiresAt:         link.ExpiresAt,
+		ActivatesAt:       activatesAt,
+		PasswordProtected: link.PasswordHash != "",
+		MaxClicks:         maxClicks,
+		ClicksLeft:        clicksLeft,
+		RedirectStatus:    redirectStatus,
+		Passthrough:       link.Passthrough,
+		Rules:             apiRules,
+		Variants:          apiVariants,
+		Clicks:            clicks,
+	}
+}
+
+func handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{Code: code, Message: message}})
+}
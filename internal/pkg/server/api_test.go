@@ -0,0 +1,207 @@
+package server
+
+import (
+	"2links/internal/pkg/apikey"
+	"2links/internal/pkg/saving"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiClient sends requests through the API routes with a key of testUser.
+type apiClient struct {
+	mux *http.ServeMux
+	key string
+}
+
+func newAPIClient(t *testing.T, s *Server, db saving.Store, scope string) apiClient {
+	t.Helper()
+
+	key, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if err := db.SaveAPIKey(testUser, prefix, hash, scope); err != nil {
+		t.Fatalf("SaveAPIKey: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	s.registerAPI(mux)
+	return apiClient{mux: mux, key: key}
+}
+
+func (c apiClient) do(method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, apiPrefix+path, strings.NewReader(body))
+	if c.key != "" {
+		r.Header.Set("Authorization", "Bearer "+c.key)
+	}
+
+	w := httptest.NewRecorder()
+	c.mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestAPIAuth(t *testing.T) {
+	s, db := newTestServer(t)
+	reader := newAPIClient(t, s, db, apikey.ScopeRead)
+
+	tests := []struct {
+		client apiClient
+		method string
+		status int
+	}{
+		{apiClient{mux: reader.mux}, http.MethodGet, http.StatusUnauthorized},
+		{apiClient{mux: reader.mux, key: "2l_nonsense"}, http.MethodGet, http.StatusUnauthorized},
+		{apiClient{mux: reader.mux, key: reader.key + "x"}, http.MethodGet, http.StatusUnauthorized},
+		{reader, http.MethodGet, http.StatusOK},
+		{reader, http.MethodPost, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		if w := test.client.do(test.method, "/links", `{"url": "https://example.com"}`); w.Code != test.status {
+			t.Errorf("%s /links with key %q = %d, want %d", test.method, test.client.key, w.Code, test.status)
+		}
+	}
+}
+
+func TestAPILinks(t *testing.T) {
+	s, db := newTestServer(t)
+	client := newAPIClient(t, s, db, apikey.ScopeWrite)
+
+	w := client.do(http.MethodPost, "/links", `{"url": "https://example.com/page", "alias": "promo", "max_clicks": 5,
+		"redirect_status": 307, "utm": {"source": "tg", "campaign": "fall sale"},
+		"rules": [{"kind": "device", "match": "iOS", "target_url": "https://apps.apple.com"}],
+		"variants": [{"target_url": "https://example.com/a"}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /links = %d: %s", w.Code, w.Body)
+	}
+
+	var link apiLink
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatalf("decoding the link: %v", err)
+	}
+
+	if link.Code != "promo" || link.ShortURL != "http://2l.test/promo" ||
+		link.OriginalURL != "https://example.com/page?utm_source=tg&utm_campaign=fall+sale" ||
+		*link.MaxClicks != 5 || *link.RedirectStatus != 307 || len(link.Rules) != 1 || link.Rules[0].Match != "ios" ||
+		len(link.Variants) != 1 || link.Variants[0].Weight != 1 {
+		t.Errorf("created link = %+v", link)
+	}
+
+	invalid := []struct {
+		body   string
+		status int
+	}{
+		{`{"url": "https://example.org", "alias": "promo"}`, http.StatusConflict},
+		{`{"url": "https://example.org", "alias": "api"}`, http.StatusUnprocessableEntity},
+		{`{"url": "not a url"}`, http.StatusUnprocessableEntity},
+		{`{"url": "https://example.org", "redirect_status": 303}`, http.StatusUnprocessableEntity},
+		{`{"url": "https://example.org", "rules": [{"kind": "device", "match": "tv", "target_url": "https://example.org"}]}`, http.StatusUnprocessableEntity},
+		{`{"url": "https://example.org", "utm_preset": "missing"}`, http.StatusUnprocessableEntity},
+		{`[]`, http.StatusBadRequest},
+	}
+
+	for _, test := range invalid {
+		if w := client.do(http.MethodPost, "/links", test.body); w.Code != test.status {
+			t.Errorf("POST /links %s = %d, want %d", test.body, w.Code, test.status)
+		}
+	}
+
+	if w := client.do(http.MethodGet, "/links/promo", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /links/promo = %d", w.Code)
+	}
+
+	if w := client.do(http.MethodGet, "/links/promo/stats", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /links/promo/stats = %d", w.Code)
+	}
+
+	if w := client.do(http.MethodPatch, "/links/promo", `{"url": "https://example.org"}`); w.Code != http.StatusOK {
+		t.Errorf("PATCH /links/promo = %d", w.Code)
+	}
+
+	var revisions []apiRevision
+	w = client.do(http.MethodGet, "/links/promo/revisions", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &revisions); err != nil || len(revisions) != 2 || revisions[0].OriginalURL != "https://example.org" {
+		t.Errorf("GET /links/promo/revisions = %d, %+v, %v", w.Code, revisions, err)
+	}
+
+	if w := client.do(http.MethodDelete, "/links/promo", ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE /links/promo = %d, want 204", w.Code)
+	}
+
+	if w := client.do(http.MethodGet, "/links/promo", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET of a deleted link = %d, want 404", w.Code)
+	}
+}
+
+func TestAPIScopesLinksToUser(t *testing.T) {
+	s, db := newTestServer(t)
+	client := newAPIClient(t, s, db, apikey.ScopeWrite)
+
+	if err := db.AddUser(2); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	if err := db.SaveLink(saving.NewLink{UserID: 2, ShortURL: "theirs", OriginalURL: "https://example.com", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("SaveLink: %v", err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		if w := client.do(method, "/links/theirs", `{"url": "https://example.org"}`); w.Code != http.StatusNotFound {
+			t.Errorf("%s of another user's link = %d, want 404", method, w.Code)
+		}
+	}
+
+	if !db.LinkInBase("theirs") {
+		t.Error("another user's link was deleted")
+	}
+}
+
+func patchLink(s *Server, code, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPatch, apiPrefix+"/links/"+code, strings.NewReader(body))
+	r.SetPathValue("code", code)
+	w := httptest.NewRecorder()
+	s.handleUpdateLink(w, r, testUser)
+	return w
+}
+
+func TestUpdateLinkClearsActivation(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "soon", ActivatesAt: time.Now().Add(time.Minute)})
+
+	w := patchLink(s, "soon", `{"passthrough": true}`)
+	if link, _ := db.GetUserLink(testUser, "soon"); w.Code != http.StatusOK || link.ActivatesAt.IsZero() {
+		t.Fatalf("PATCH without activates_at = %d, activation %v; want it kept", w.Code, link.ActivatesAt)
+	}
+
+	w = patchLink(s, "soon", `{"activates_at": null}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH activates_at null = %d: %s", w.Code, w.Body)
+	}
+
+	var link apiLink
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil || link.ActivatesAt != nil {
+		t.Errorf("activates_at after clearing = %v, %v; want null", link.ActivatesAt, err)
+	}
+}
+
+func TestUpdateLinkIsAllOrNothing(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "keep"})
+
+	w := patchLink(s, "keep", `{"url": "https://example.org", "password": "secret123", "max_clicks": -1}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("PATCH with an invalid field = %d, want 422", w.Code)
+	}
+
+	link, err := db.GetUserLink(testUser, "keep")
+	if err != nil || link.OriginalURL != "https://example.com/keep" || link.PasswordHash != "" {
+		t.Errorf("link after a rejected PATCH = %+v, %v; want it unchanged", link, err)
+	}
+
+	if w := patchLink(s, "missing", `{"url": "https://example.org"}`); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH of a missing link = %d, want 404", w.Code)
+	}
+}
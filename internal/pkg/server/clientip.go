@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid trusted proxy %q: %w", entry, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+
+	return proxies, nil
+}
+
+// clientIP trusts forwarding headers only when the connection comes from a
+// trusted proxy. Only X-Forwarded-For, or X-Real-IP when it is absent, is
+// read: proxies such as nginx append to X-Forwarded-For but pass any other
+// header from the client through as is. The hop chain is walked from the
+// right, skipping trusted proxies, so a client cannot spoof its address by
+// prepending entries.
+func (s *Server) clientIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+
+	remote = remote.Unmap()
+	if !s.trustedProxy(remote) {
+		return remote.String()
+	}
+
+	hops := xForwardedHops(r.Header)
+	if hops == nil {
+		hops = r.Header.Values("X-Real-IP")
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+
+		client = addr
+		if !s.trustedProxy(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	for _, prefix := range s.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func xForwardedHops(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	return hops
+}
+
+func parseHop(hop string) (netip.Addr, bool) {
+	if strings.HasPrefix(hop, "[") {
+		end := strings.Index(hop, "]")
+		if end < 0 {
+			return netip.Addr{}, false
+		}
+		hop = hop[1:end]
+	} else if strings.Count(hop, ":") == 1 {
+		hop, _, _ = strings.Cut(hop, ":")
+	}
+
+	addr, err := netip.ParseAddr(hop)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseHop(t *testing.T) {
+	tests := []struct {
+		hop  string
+		want string
+		ok   bool
+	}{
+		{"203.0.113.7", "203.0.113.7", true},
+		{"203.0.113.7:51000", "203.0.113.7", true},
+		{"2001:db8::1", "2001:db8::1", true},
+		{"[2001:db8::1]", "2001:db8::1", true},
+		{"[2001:db8::1]:443", "2001:db8::1", true},
+		{"::ffff:203.0.113.7", "203.0.113.7", true},
+		{"[2001:db8::1", "", false},
+		{"unknown", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		addr, ok := parseHop(test.hop)
+		if ok != test.ok || ok && addr.String() != test.want {
+			t.Errorf("parseHop(%q) = %v, %t; want %s, %t", test.hop, addr, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("127.0.0.1, 10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	s := &Server{trustedProxies: proxies}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{"direct", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"untrusted remote ignores headers", "203.0.113.7:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.7"},
+		{"trusted proxy without headers", "127.0.0.1:1234", nil, "127.0.0.1"},
+		{"forwarded for", "127.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"spoofed prefix", "127.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1"}}, "198.51.100.1"},
+		{"proxy chain", "127.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, 10.0.0.5"}}, "198.51.100.1"},
+		{"repeated header", "127.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4", "198.51.100.1"}}, "198.51.100.1"},
+		{"garbage hop", "127.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4, garbage"}}, "127.0.0.1"},
+		{"real ip", "127.0.0.1:1234", map[string][]string{"X-Real-IP": {"198.51.100.1"}}, "198.51.100.1"},
+		{"forwarded for wins over real ip", "127.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}, "X-Real-IP": {"1.2.3.4"}}, "198.51.100.1"},
+		{"forwarded header is ignored", "127.0.0.1:1234", map[string][]string{"Forwarded": {"for=1.2.3.4"}, "X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"mapped remote", "[::ffff:127.0.0.1]:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remote
+		for key, values := range test.headers {
+			for _, value := range values {
+				r.Header.Add(key, value)
+			}
+		}
+
+		if got := s.clientIP(r); got != test.want {
+			t.Errorf("%s: clientIP = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
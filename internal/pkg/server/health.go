@@ -0,0 +1,63 @@
+package server
+
+import (
+	"2links/internal/pkg/health"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type healthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]healthComponent `json:"components,omitempty"`
+}
+
+type healthComponent struct {
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	LastPoll *time.Time `json:"last_poll,omitempty"`
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, healthResponse{Status: statusOK})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{Status: statusOK, Components: make(map[string]healthComponent)}
+
+	database := healthComponent{Status: statusOK}
+	if err := s.db.Ping(); err != nil {
+		log.Printf("Readiness check failed: %v", err)
+		database = healthComponent{Status: statusUnavailable, Error: err.Error()}
+		response.Status = statusUnavailable
+	}
+	response.Components["database"] = database
+
+	for _, poller := range health.Pollers() {
+		component := healthComponent{Status: statusOK}
+		if !poller.LastSuccess.IsZero() {
+			component.LastPoll = &poller.LastSuccess
+		}
+
+		if poller.Err != nil {
+			component.Status = statusUnavailable
+			component.Error = poller.Err.Error()
+			response.Status = statusUnavailable
+		}
+		response.Components[poller.Name] = component
+	}
+
+	status := http.StatusOK
+	if response.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, response)
+}
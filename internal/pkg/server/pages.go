@@ -0,0 +1,59 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+type page struct {
+	Title        string
+	Message      string
+	PasswordForm bool
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} — 2links</title>
+</head>
+<body style="font-family: sans-serif; max-width: 420px; margin: 80px auto; padding: 0 16px; text-align: center;">
+<h1>{{.Title}}</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if .PasswordForm}}<form method="post">
+<input type="password" name="password" autofocus required>
+<button type="submit">Открыть</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+func renderPage(w http.ResponseWriter, status int, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := pageTemplate.Execute(w, p); err != nil {
+		log.Printf("Error rendering page: %v", err)
+	}
+}
+
+var exhaustedPage = page{
+	Title:   "Ссылка больше не действует",
+	Message: "Лимит переходов по этой ссылке исчерпан.",
+}
+
+var previewPage = page{
+	Title:   "Ссылка 2links",
+	Message: "Откройте ссылку в браузере, чтобы перейти по ней.",
+}
+
+var pendingPage = page{
+	Title:   "Ссылка ещё не доступна",
+	Message: "Эта ссылка начнёт работать позже. Попробуйте открыть её ближе к дате запуска.",
+}
+
+func passwordPage(message string) page {
+	return page{Title: "Ссылка защищена паролем", Message: message, PasswordForm: true}
+}
@@ -1,63 +1,351 @@
 package server
 
 import (
+	"2links/internal/pkg/clickqueue"
+	"2links/internal/pkg/geoip"
+	"2links/internal/pkg/lifecycle"
+	"2links/internal/pkg/metrics"
 	"2links/internal/pkg/saving"
-	"database/sql"
+	"2links/internal/pkg/shortener"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	permanentCacheMaxAge = 24 * time.Hour
+	variantCookieMaxAge  = 30 * 24 * time.Hour
+	variantCookiePrefix  = "2l_variant_"
+)
+
+// previewAgents are lowercased parts of the user agents that messengers and
+// social networks use to fetch a link preview.
+var previewAgents = []string{
+	"telegrambot", "whatsapp", "facebookexternalhit", "facebot", "twitterbot", "slackbot", "discordbot",
+	"linkedinbot", "vkshare", "skypeuripreview", "viber", "redditbot", "pinterest", "embedly",
+}
+
 type Server struct {
+	db              saving.Store
+	links           *shortener.Shortener
+	url             string
+	redirectStatus  int
+	geo             *geoip.DB
+	trustedProxies  []netip.Prefix
+	clicks          *clickqueue.Queue
+	passwordLimiter *attemptLimiter
+	webhooks        map[string]http.Handler
 }
 
-func NewServer(db *sql.DB, url string) *Server {
-	return &Server{}
+func NewServer(db saving.Store, links *shortener.Shortener, url string, redirectStatus int, geo *geoip.DB,
+	trustedProxies []netip.Prefix, clicks *clickqueue.Queue) *Server {
+	return &Server{
+		db:              db,
+		links:           links,
+		clicks:          clicks,
+		url:             url,
+		redirectStatus:  redirectStatus,
+		geo:             geo,
+		trustedProxies:  trustedProxies,
+		passwordLimiter: newAttemptLimiter(passwordAttempts, passwordWindow),
+		webhooks:        make(map[string]http.Handler),
+	}
 }
 
-func (s *Server) Start(port string, db *sql.DB) {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		s.handleRedirect(w, r, db)
-	})
+// HandleWebhook serves POST requests to path with handler. It must be called
+// before Start.
+func (s *Server) HandleWebhook(path string, handler http.Handler) {
+	s.webhooks[path] = handler
+}
+
+func (s *Server) Start(ctx context.Context, port string) error {
+	mux := http.NewServeMux()
+	s.registerAPI(mux)
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("/healthz", handleMethodNotAllowed)
+	mux.HandleFunc("/readyz", handleMethodNotAllowed)
+	for path, handler := range s.webhooks {
+		mux.Handle("POST "+path, handler)
+	}
+	mux.HandleFunc("/", s.handleRedirect)
 
 	log.Printf("Server is running on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	return lifecycle.ServeHTTP(ctx, &http.Server{Addr: ":" + port, Handler: mux})
 }
 
-func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	shortCode := r.URL.Path[1:]
+func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	outcome := metrics.OutcomeNotFound
+	defer func() { metrics.ObserveRedirect(outcome, now) }()
+
+	shortCode, extraPath, _ := strings.Cut(r.URL.EscapedPath()[1:], "/")
 	if shortCode == "" {
 		http.NotFound(w, r)
 		return
 	}
 
-	originalURL, linkID, exp, err := saving.GetOriginalURL(db, shortCode)
-	if err != nil {
+	link, err := s.db.GetOriginalURL(shortCode)
+	if err != nil && !errors.Is(err, saving.ErrLinkNotFound) {
+		log.Printf("Failed to resolve short link: %v", err)
+		outcome = metrics.OutcomeError
+	}
+
+	if err != nil || extraPath != "" && (!link.Passthrough || !safeExtraPath(extraPath)) {
 		http.NotFound(w, r)
 		return
 	}
 
-	if time.Now().After(exp) {
+	if now.After(link.ExpiresAt) {
+		outcome = metrics.OutcomeExpired
 		http.NotFound(w, r)
 		return
 	}
-	ipAddress := r.RemoteAddr
-	if colonIndex := strings.LastIndex(ipAddress, ":"); colonIndex != -1 {
-		ipAddress = ipAddress[:colonIndex]
+
+	if link.Pending(now) {
+		outcome = metrics.OutcomePending
+		renderPage(w, http.StatusForbidden, pendingPage)
+		return
+	}
+
+	if link.Exhausted() {
+		outcome = metrics.OutcomeExhausted
+		renderPage(w, http.StatusGone, exhaustedPage)
+		return
+	}
+
+	ipAddress := s.clientIP(r)
+	if link.PasswordHash != "" && !s.checkPassword(w, r, link, ipAddress) {
+		outcome = metrics.OutcomePassword
+		return
+	}
+
+	// Link previews and HEAD requests do not open the link, so they neither
+	// use up its clicks nor show up in its stats. A link with a click budget
+	// does not tell them where it leads either, or a spoofed user agent would
+	// open it for free.
+	preview := isPreview(r)
+	if link.MaxClicks > 0 && preview {
+		outcome = metrics.OutcomeFound
+		renderPage(w, http.StatusOK, previewPage)
+		return
+	}
+
+	if link.MaxClicks > 0 {
+		allowed, err := s.db.ConsumeClick(link.ID)
+		if err != nil {
+			log.Printf("Failed to consume click: %v", err)
+			outcome = metrics.OutcomeError
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if !allowed {
+			outcome = metrics.OutcomeExhausted
+			renderPage(w, http.StatusGone, exhaustedPage)
+			return
+		}
+	}
+
+	outcome = metrics.OutcomeFound
+
+	rules, err := s.db.GetLinkRules(link.ID)
+	if err != nil {
+		log.Printf("Failed to fetch link rules: %v", err)
 	}
 
 	userAgent := r.Header.Get("User-Agent")
-	err = saving.SaveClick(db, linkID, ipAddress, userAgent)
+	location := s.geo.Lookup(ipAddress)
+	visitor := shortener.Visitor{Device: shortener.DetectDevice(userAgent), Country: location.Country}
+
+	variants, err := s.db.GetLinkVariants(link.ID)
 	if err != nil {
-		log.Printf("Failed to save click: %v", err)
+		log.Printf("Failed to fetch link variants: %v", err)
+	}
+
+	originalURL := link.OriginalURL
+	var matchedRule string
+	var variantID int
+	if rule, ok := shortener.MatchRule(rules, visitor); ok {
+		originalURL = rule.TargetURL
+		matchedRule = rule.String()
+	} else if variant, ok := pickVariant(w, r, shortCode, variants); ok {
+		originalURL = variant.TargetURL
+		variantID = variant.ID
+	}
+
+	if !preview {
+		s.clicks.Enqueue(saving.Click{
+			LinkID:      link.ID,
+			RevisionID:  link.RevisionID,
+			VariantID:   variantID,
+			IPAddress:   ipAddress,
+			UserAgent:   userAgent,
+			MatchedRule: matchedRule,
+			Country:     location.Country,
+			City:        location.City,
+		})
 	}
 
 	if !startsWithProtocol(originalURL) {
 		originalURL = "http://" + originalURL
 	}
 
-	http.Redirect(w, r, originalURL, http.StatusFound)
+	if link.Passthrough {
+		originalURL = forwardRequest(originalURL, extraPath, r.URL.Query())
+	}
+
+	status := link.RedirectStatus
+	if status == 0 {
+		status = s.redirectStatus
+	}
+
+	// After the password form the browser must follow up with a GET rather
+	// than repost the password to the destination.
+	if r.Method == http.MethodPost && link.PasswordHash != "" {
+		status = http.StatusSeeOther
+	}
+
+	w.Header().Set("Cache-Control", cacheControl(link, status, now, len(rules) > 0 || len(variants) > 0))
+	http.Redirect(w, r, originalURL, status)
+}
+
+func pickVariant(w http.ResponseWriter, r *http.Request, shortCode string, variants []saving.LinkVariant) (saving.LinkVariant, bool) {
+	if len(variants) == 0 {
+		return saving.LinkVariant{}, false
+	}
+
+	var stickyID int
+	if cookie, err := r.Cookie(variantCookiePrefix + shortCode); err == nil {
+		stickyID, _ = strconv.Atoi(cookie.Value)
+	}
+
+	variant, ok := shortener.PickVariant(variants, stickyID)
+	if !ok {
+		return variant, false
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookiePrefix + shortCode,
+		Value:    strconv.Itoa(variant.ID),
+		Path:     "/" + shortCode,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return variant, true
+}
+
+func isPreview(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return true
+	}
+
+	userAgent := strings.ToLower(r.Header.Get("User-Agent"))
+	for _, agent := range previewAgents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func cacheControl(link saving.Link, status int, now time.Time, personalized bool) string {
+	permanent := status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+	if !permanent || link.PasswordHash != "" || link.MaxClicks > 0 {
+		return "no-store"
+	}
+
+	visibility := "public"
+	if personalized {
+		visibility = "private"
+	}
+
+	maxAge := min(link.ExpiresAt.Sub(now), permanentCacheMaxAge)
+	return fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds()))
+}
+
+// safeExtraPath reports whether the visitor's trailing path stays under the
+// destination's path: joining it would resolve "." and ".." segments, escaped
+// ones included, and climb above the configured path.
+func safeExtraPath(extraPath string) bool {
+	unescaped, err := url.PathUnescape(extraPath)
+	if err != nil {
+		return false
+	}
+
+	for _, segment := range strings.Split(unescaped, "/") {
+		if segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// forwardRequest appends the visitor's trailing path and query parameters to
+// the destination. Parameters already present in the destination win: the
+// visitor's values for those keys are dropped.
+func forwardRequest(destination string, extraPath string, query url.Values) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		log.Printf("Failed to parse destination %q: %v", destination, err)
+		return destination
+	}
+
+	if extraPath != "" {
+		u = u.JoinPath(extraPath)
+	}
+
+	own := u.Query()
+	forwarded := url.Values{}
+	for key, values := range query {
+		if _, ok := own[key]; !ok {
+			forwarded[key] = values
+		}
+	}
+
+	if len(forwarded) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += forwarded.Encode()
+	}
+
+	return u.String()
+}
+
+func (s *Server) checkPassword(w http.ResponseWriter, r *http.Request, link saving.Link, ipAddress string) bool {
+	if r.Method != http.MethodPost {
+		renderPage(w, http.StatusOK, passwordPage(""))
+		return false
+	}
+
+	if !s.passwordLimiter.Allowed(ipAddress) {
+		renderPage(w, http.StatusTooManyRequests, page{
+			Title:   "Слишком много попыток",
+			Message: "Попробуйте ещё раз через несколько минут.",
+		})
+		return false
+	}
+
+	if !shortener.CheckLinkPassword(r.PostFormValue("password"), link.PasswordHash) {
+		s.passwordLimiter.Fail(ipAddress)
+		renderPage(w, http.StatusUnauthorized, passwordPage("Неверный пароль, попробуйте ещё раз."))
+		return false
+	}
+
+	s.passwordLimiter.Reset(ipAddress)
+	return true
 }
 
 func startsWithProtocol(url string) bool {
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"2links/internal/pkg/clickqueue"
+	"2links/internal/pkg/saving"
+	"2links/internal/pkg/shortener"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testUser int64 = 1
+
+func newTestServer(t *testing.T) (*Server, saving.Store) {
+	t.Helper()
+
+	db := saving.NewMemory()
+	if err := db.AddUser(testUser); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+
+	clicks := clickqueue.New(db, 10, 10, time.Hour)
+	t.Cleanup(clicks.Close)
+
+	return NewServer(db, shortener.New(db, shortener.Config{}), "http://2l.test/", http.StatusFound, nil, nil, clicks), db
+}
+
+// addLink saves link for testUser, filling in the destination and expiry when
+// they are not set.
+func addLink(t *testing.T, db saving.Store, link saving.NewLink) {
+	t.Helper()
+
+	link.UserID = testUser
+	if link.OriginalURL == "" {
+		link.OriginalURL = "https://example.com/" + link.ShortURL
+	}
+	if link.ExpiresAt.IsZero() {
+		link.ExpiresAt = time.Now().Add(time.Hour)
+	}
+
+	if err := db.SaveLink(link); err != nil {
+		t.Fatalf("SaveLink(%s): %v", link.ShortURL, err)
+	}
+}
+
+func serve(s *Server, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.handleRedirect(w, r)
+	return w
+}
+
+func TestPreviewDoesNotOpenBudgetLink(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "once", MaxClicks: 1})
+
+	preview := httptest.NewRequest(http.MethodGet, "/once", nil)
+	preview.Header.Set("User-Agent", "TelegramBot (like TwitterBot)")
+	head := httptest.NewRequest(http.MethodHead, "/once", nil)
+
+	for _, r := range []*http.Request{preview, head, preview, head} {
+		w := serve(s, r)
+		if w.Code != http.StatusOK || w.Header().Get("Location") != "" {
+			t.Fatalf("%s with %q = %d, Location %q; want 200 without Location",
+				r.Method, r.UserAgent(), w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	w := serve(s, httptest.NewRequest(http.MethodGet, "/once", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/once" {
+		t.Fatalf("first visit = %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	for _, r := range []*http.Request{httptest.NewRequest(http.MethodGet, "/once", nil), preview, head} {
+		w := serve(s, r)
+		if w.Code != http.StatusGone || w.Header().Get("Location") != "" {
+			t.Errorf("%s with %q after the last click = %d, Location %q; want 410",
+				r.Method, r.UserAgent(), w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestPreviewFollowsUnlimitedLink(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "open"})
+
+	r := httptest.NewRequest(http.MethodGet, "/open", nil)
+	r.Header.Set("User-Agent", "WhatsApp/2.23")
+	w := serve(s, r)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/open" {
+		t.Errorf("preview of an unlimited link = %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestRedirectStatusOnPost(t *testing.T) {
+	s, db := newTestServer(t)
+	hash, err := shortener.HashLinkPassword("secret123")
+	if err != nil {
+		t.Fatalf("HashLinkPassword: %v", err)
+	}
+	addLink(t, db, saving.NewLink{ShortURL: "form", RedirectStatus: http.StatusTemporaryRedirect})
+	addLink(t, db, saving.NewLink{ShortURL: "locked", RedirectStatus: http.StatusTemporaryRedirect, PasswordHash: hash})
+
+	w := serve(s, httptest.NewRequest(http.MethodPost, "/form", nil))
+	if w.Code != http.StatusTemporaryRedirect {
+		t.Errorf("POST to a 307 link = %d, want 307", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/locked", strings.NewReader("password=secret123"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(s, r)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "https://example.com/locked" {
+		t.Errorf("password form = %d, Location %q; want 303", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestForwardRequest(t *testing.T) {
+	tests := []struct {
+		destination string
+		extraPath   string
+		query       string
+		want        string
+	}{
+		{"https://example.com/docs", "", "", "https://example.com/docs"},
+		{"https://example.com/docs", "guide/intro", "", "https://example.com/docs/guide/intro"},
+		{"https://example.com/docs/", "guide", "", "https://example.com/docs/guide"},
+		{"https://example.com", "a%20b", "", "https://example.com/a%20b"},
+		{"https://example.com/docs", "", "ref=tg&page=2", "https://example.com/docs?page=2&ref=tg"},
+		{"https://example.com/docs?ref=site", "", "ref=tg&page=2", "https://example.com/docs?ref=site&page=2"},
+		{"https://example.com/docs?ref=site", "guide", "x=1", "https://example.com/docs/guide?ref=site&x=1"},
+	}
+
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		if got := forwardRequest(test.destination, test.extraPath, query); got != test.want {
+			t.Errorf("forwardRequest(%q, %q, %q) = %q, want %q", test.destination, test.extraPath, test.query, got, test.want)
+		}
+	}
+}
+
+func TestPassthroughStaysUnderDestination(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "docs", OriginalURL: "https://example.com/docs", Passthrough: true})
+
+	w := serve(s, httptest.NewRequest(http.MethodGet, "/docs/guide?x=1", nil))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/docs/guide?x=1" {
+		t.Errorf("passthrough = %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	for _, path := range []string{"/docs/..", "/docs/../admin", "/docs/guide/../../admin", "/docs/%2e%2e/admin",
+		"/docs/%2E%2e/admin", "/docs/.%2e/admin", "/docs/./guide", "/docs/%2e/guide", "/docs/..%2fadmin"} {
+		w := serve(s, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, Location %q; want 404", path, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "plain", OriginalURL: "example.com/page"})
+	addLink(t, db, saving.NewLink{ShortURL: "moved", RedirectStatus: http.StatusMovedPermanently})
+	addLink(t, db, saving.NewLink{ShortURL: "gone", ExpiresAt: time.Now().Add(-time.Minute)})
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/plain", http.StatusFound, "http://example.com/page"},
+		{"/moved", http.StatusMovedPermanently, "https://example.com/moved"},
+		{"/gone", http.StatusNotFound, ""},
+		{"/missing", http.StatusNotFound, ""},
+		{"/plain/extra", http.StatusNotFound, ""},
+		{"/", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		w := serve(s, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status || w.Header().Get("Location") != test.location {
+			t.Errorf("GET %s = %d, Location %q; want %d, %q", test.path, w.Code, w.Header().Get("Location"), test.status, test.location)
+		}
+	}
+
+	if w := serve(s, httptest.NewRequest(http.MethodGet, "/moved", nil)); !strings.HasPrefix(w.Header().Get("Cache-Control"), "public, max-age=") {
+		t.Errorf("permanent redirect Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+	if w := serve(s, httptest.NewRequest(http.MethodGet, "/plain", nil)); w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("temporary redirect Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestPendingLink(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "soon", ActivatesAt: time.Now().Add(time.Minute)})
+
+	w := serve(s, httptest.NewRequest(http.MethodGet, "/soon", nil))
+	if w.Code != http.StatusForbidden || w.Header().Get("Location") != "" || !strings.Contains(w.Body.String(), pendingPage.Title) {
+		t.Errorf("pending link = %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestClickBudget(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "twice", MaxClicks: 2})
+
+	for i, want := range []int{http.StatusFound, http.StatusFound, http.StatusGone, http.StatusGone} {
+		w := serve(s, httptest.NewRequest(http.MethodGet, "/twice", nil))
+		if w.Code != want {
+			t.Errorf("visit #%d = %d, want %d", i+1, w.Code, want)
+		}
+		if w.Code == http.StatusFound && w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("visit #%d Cache-Control = %q, want no-store", i+1, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestPasswordLink(t *testing.T) {
+	s, db := newTestServer(t)
+	hash, err := shortener.HashLinkPassword("secret123")
+	if err != nil {
+		t.Fatalf("HashLinkPassword: %v", err)
+	}
+	addLink(t, db, saving.NewLink{ShortURL: "locked", PasswordHash: hash, MaxClicks: 10})
+
+	post := func(password, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/locked", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = ip + ":1234"
+		return serve(s, r)
+	}
+
+	w := serve(s, httptest.NewRequest(http.MethodGet, "/locked", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Location") != "" || !strings.Contains(w.Body.String(), `type="password"`) {
+		t.Errorf("GET of a locked link = %d, Location %q; want the password form", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := post("wrong", "203.0.113.1"); w.Code != http.StatusUnauthorized || w.Header().Get("Location") != "" {
+		t.Errorf("wrong password = %d, Location %q; want 401", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := post("secret123", "203.0.113.1"); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "https://example.com/locked" {
+		t.Errorf("right password = %d, Location %q; want 303", w.Code, w.Header().Get("Location"))
+	}
+
+	for range passwordAttempts {
+		post("wrong", "203.0.113.2")
+	}
+	if w := post("secret123", "203.0.113.2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("password after %d failures = %d, want 429", passwordAttempts, w.Code)
+	}
+
+	if link, _ := db.GetUserLink(testUser, "locked"); link.ClicksLeft != 9 {
+		t.Errorf("ClicksLeft = %d, want only the opened visit counted", link.ClicksLeft)
+	}
+}
+
+func TestRules(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "app", Rules: []saving.LinkRule{
+		{Kind: shortener.RuleDevice, Match: shortener.DeviceIOS, TargetURL: "https://apps.apple.com/app"},
+		{Kind: shortener.RuleDevice, Match: shortener.DeviceAndroid, TargetURL: "https://play.google.com/app"},
+	}})
+
+	tests := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)": "https://apps.apple.com/app",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile":        "https://play.google.com/app",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64)":              "https://example.com/app",
+	}
+
+	for userAgent, want := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/app", nil)
+		r.Header.Set("User-Agent", userAgent)
+		if w := serve(s, r); w.Header().Get("Location") != want {
+			t.Errorf("%s: Location %q, want %q", userAgent, w.Header().Get("Location"), want)
+		}
+	}
+}
+
+func TestVariantsStickToVisitor(t *testing.T) {
+	s, db := newTestServer(t)
+	addLink(t, db, saving.NewLink{ShortURL: "ab", Variants: []saving.LinkVariant{
+		{TargetURL: "https://example.com/a", Weight: 1},
+		{TargetURL: "https://example.com/b", Weight: 1},
+	}})
+
+	w := serve(s, httptest.NewRequest(http.MethodGet, "/ab", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != variantCookiePrefix+"ab" || cookies[0].Path != "/ab" {
+		t.Fatalf("cookies = %v, want the variant cookie", cookies)
+	}
+
+	first := w.Header().Get("Location")
+	if first != "https://example.com/a" && first != "https://example.com/b" {
+		t.Fatalf("Location %q, want one of the variants", first)
+	}
+
+	for range 20 {
+		r := httptest.NewRequest(http.MethodGet, "/ab", nil)
+		r.AddCookie(cookies[0])
+		if w := serve(s, r); w.Header().Get("Location") != first {
+			t.Fatalf("returning visitor went to %q, want %q", w.Header().Get("Location"), first)
+		}
+	}
+
+	if w := serve(s, httptest.NewRequest(http.MethodGet, "/ab", nil)); w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+	}
+}
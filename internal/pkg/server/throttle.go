@@ -0,0 +1,82 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	passwordAttempts = 5
+	passwordWindow   = 15 * time.Minute
+
+	limiterPurgeSize = 10000
+)
+
+type attemptLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	attempts map[string]*attempts
+}
+
+type attempts struct {
+	count int
+	first time.Time
+}
+
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string]*attempts),
+	}
+}
+
+func (l *attemptLimiter) Allowed(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.attempts[key]
+	if !ok {
+		return true
+	}
+
+	if time.Since(a.first) > l.window {
+		delete(l.attempts, key)
+		return true
+	}
+
+	return a.count < l.limit
+}
+
+func (l *attemptLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.attempts) >= limiterPurgeSize {
+		l.purge()
+	}
+
+	a, ok := l.attempts[key]
+	if !ok || time.Since(a.first) > l.window {
+		l.attempts[key] = &attempts{count: 1, first: time.Now()}
+		return
+	}
+
+	a.count++
+}
+
+func (l *attemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+func (l *attemptLimiter) purge() {
+	for key, a := range l.attempts {
+		if time.Since(a.first) > l.window {
+			delete(l.attempts, key)
+		}
+	}
+}
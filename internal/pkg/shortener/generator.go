@@ -0,0 +1,160 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+const (
+	GeneratorRandom     = "random"
+	GeneratorSequential = "sequential"
+	GeneratorHashids    = "hashids"
+	GeneratorWords      = "words"
+
+	consonants = "bdfghklmnprstvz"
+	vowels     = "aeiou"
+)
+
+type CodeGenerator interface {
+	Generate() (string, error)
+}
+
+type RandomGenerator struct {
+	Length int
+}
+
+type SequentialGenerator struct {
+	Db saving.Store
+}
+
+type HashidsGenerator struct {
+	Db        saving.Store
+	Salt      string
+	MinLength int
+}
+
+type WordGenerator struct {
+	Syllables int
+}
+
+func NewGenerator(name string, length int, salt string, Db saving.Store) (CodeGenerator, error) {
+	switch name {
+	case GeneratorRandom, "":
+		if length <= 0 {
+			length = 4
+		}
+		return &RandomGenerator{Length: length}, nil
+
+	case GeneratorSequential:
+		return &SequentialGenerator{Db: Db}, nil
+
+	case GeneratorHashids:
+		if salt == "" {
+			return nil, fmt.Errorf("Hashids generator requires a salt")
+		}
+		return &HashidsGenerator{Db: Db, Salt: salt, MinLength: length}, nil
+
+	case GeneratorWords:
+		if length <= 0 {
+			length = 3
+		}
+		return &WordGenerator{Syllables: length}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown code generator %q", name)
+}
+
+func (g *RandomGenerator) Generate() (string, error) {
+	var b strings.Builder
+	for range g.Length {
+		b.WriteByte(symbols[rand.Intn(len(symbols))])
+	}
+
+	return b.String(), nil
+}
+
+func (g *SequentialGenerator) Generate() (string, error) {
+	n, err := g.Db.NextCodeSequence()
+	if err != nil {
+		return "", err
+	}
+
+	return encodeBase(n, symbols), nil
+}
+
+func (g *HashidsGenerator) Generate() (string, error) {
+	n, err := g.Db.NextCodeSequence()
+	if err != nil {
+		return "", err
+	}
+
+	return g.encode(n), nil
+}
+
+func (g *HashidsGenerator) encode(n int64) string {
+	alphabet := consistentShuffle(symbols, g.Salt)
+	guard, alphabet := alphabet[0], alphabet[1:]
+
+	lottery := alphabet[n%int64(len(alphabet))]
+	alphabet = consistentShuffle(alphabet, string(lottery)+g.Salt)
+	code := string(lottery) + encodeBase(n, alphabet)
+
+	if len(code) >= g.MinLength {
+		return code
+	}
+
+	var pad strings.Builder
+	for i := 0; pad.Len() < g.MinLength-len(code)-1; i++ {
+		pad.WriteByte(alphabet[(int(n)+i*int(lottery))%len(alphabet)])
+	}
+
+	return pad.String() + string(guard) + code
+}
+
+func (g *WordGenerator) Generate() (string, error) {
+	var b strings.Builder
+	for range g.Syllables {
+		b.WriteByte(consonants[rand.Intn(len(consonants))])
+		b.WriteByte(vowels[rand.Intn(len(vowels))])
+	}
+
+	return b.String(), nil
+}
+
+func encodeBase(n int64, alphabet string) string {
+	base := int64(len(alphabet))
+	if n == 0 {
+		return string(alphabet[0])
+	}
+
+	var b []byte
+	for n > 0 {
+		b = append(b, alphabet[n%base])
+		n /= base
+	}
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
+
+func consistentShuffle(alphabet, salt string) string {
+	if salt == "" {
+		return alphabet
+	}
+
+	b := []byte(alphabet)
+	for i, v, p := len(b)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
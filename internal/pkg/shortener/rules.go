@@ -0,0 +1,112 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	RuleDevice  = "device"
+	RuleCountry = "country"
+
+	DeviceIOS     = "ios"
+	DeviceAndroid = "android"
+	DeviceDesktop = "desktop"
+	DeviceOther   = "other"
+)
+
+var (
+	ErrRuleInvalid = errors.New("Invalid link rule")
+
+	devices = map[string]bool{
+		DeviceIOS:     true,
+		DeviceAndroid: true,
+		DeviceDesktop: true,
+	}
+
+	mobileMarkers = []string{"Mobile", "Tablet", "Opera Mini", "BlackBerry", "KaiOS"}
+
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+type Visitor struct {
+	Device  string
+	Country string
+}
+
+func DetectDevice(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return DeviceIOS
+	case strings.Contains(userAgent, "Android"):
+		return DeviceAndroid
+	}
+
+	for _, marker := range mobileMarkers {
+		if strings.Contains(userAgent, marker) {
+			return DeviceOther
+		}
+	}
+
+	if strings.Contains(userAgent, "Windows") || strings.Contains(userAgent, "Macintosh") ||
+		strings.Contains(userAgent, "X11") || strings.Contains(userAgent, "CrOS") {
+		return DeviceDesktop
+	}
+
+	return DeviceOther
+}
+
+func MatchRule(rules []saving.LinkRule, visitor Visitor) (saving.LinkRule, bool) {
+	if rule, ok := findRule(rules, RuleDevice, visitor.Device); ok {
+		return rule, true
+	}
+
+	return findRule(rules, RuleCountry, visitor.Country)
+}
+
+func findRule(rules []saving.LinkRule, kind, match string) (saving.LinkRule, bool) {
+	for _, rule := range rules {
+		if rule.Kind == kind && rule.Match == match {
+			return rule, true
+		}
+	}
+
+	return saving.LinkRule{}, false
+}
+
+func NormalizeRule(rule saving.LinkRule) saving.LinkRule {
+	rule.Kind = strings.ToLower(strings.TrimSpace(rule.Kind))
+	rule.Match = strings.TrimSpace(rule.Match)
+	rule.TargetURL = strings.TrimSpace(rule.TargetURL)
+	if rule.Kind == RuleCountry {
+		rule.Match = strings.ToUpper(rule.Match)
+	} else {
+		rule.Match = strings.ToLower(rule.Match)
+	}
+
+	return rule
+}
+
+func ValidateRule(rule saving.LinkRule) error {
+	switch rule.Kind {
+	case RuleDevice:
+		if !devices[rule.Match] {
+			return fmt.Errorf("%w: device must be one of ios, android or desktop", ErrRuleInvalid)
+		}
+	case RuleCountry:
+		if !countryPattern.MatchString(rule.Match) {
+			return fmt.Errorf("%w: country must be a two-letter ISO code such as RU or DE", ErrRuleInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrRuleInvalid, rule.Kind)
+	}
+
+	if !CheckValidacy(rule.TargetURL) {
+		return fmt.Errorf("%w: target URL is not valid", ErrRuleInvalid)
+	}
+
+	return nil
+}
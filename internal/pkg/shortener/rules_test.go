@@ -0,0 +1,81 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"testing"
+)
+
+func TestDetectDevice(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148":             DeviceIOS,
+		"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)":                                    DeviceIOS,
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36":                    DeviceAndroid,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0":                           DeviceDesktop,
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Safari/605.1.15":                     DeviceDesktop,
+		"Mozilla/5.0 (X11; Linux x86_64) Firefox/121.0":                                    DeviceDesktop,
+		"Mozilla/5.0 (Windows Phone 10.0; Microsoft; Lumia 950) Mobile Safari/537.36 Edge": DeviceOther,
+		"curl/8.5.0": DeviceOther,
+		"":           DeviceOther,
+	}
+
+	for userAgent, want := range tests {
+		if got := DetectDevice(userAgent); got != want {
+			t.Errorf("DetectDevice(%q) = %q, want %q", userAgent, got, want)
+		}
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	rules := []saving.LinkRule{
+		{Kind: RuleCountry, Match: "DE", TargetURL: "https://example.de"},
+		{Kind: RuleDevice, Match: DeviceIOS, TargetURL: "https://apps.apple.com"},
+	}
+
+	tests := []struct {
+		visitor Visitor
+		want    string
+	}{
+		{Visitor{Device: DeviceIOS, Country: "DE"}, "https://apps.apple.com"},
+		{Visitor{Device: DeviceAndroid, Country: "DE"}, "https://example.de"},
+		{Visitor{Device: DeviceDesktop, Country: "RU"}, ""},
+		{Visitor{Device: DeviceDesktop}, ""},
+	}
+
+	for _, test := range tests {
+		rule, ok := MatchRule(rules, test.visitor)
+		if ok != (test.want != "") || rule.TargetURL != test.want {
+			t.Errorf("MatchRule(%+v) = %+v, %t; want %q", test.visitor, rule, ok, test.want)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	valid := []saving.LinkRule{
+		{Kind: " Device ", Match: "IOS", TargetURL: " https://apps.apple.com "},
+		{Kind: "country", Match: "de", TargetURL: "example.de"},
+	}
+
+	for _, rule := range valid {
+		if err := ValidateRule(NormalizeRule(rule)); err != nil {
+			t.Errorf("ValidateRule(%+v) = %v", rule, err)
+		}
+	}
+
+	if got := NormalizeRule(valid[1]); got.Match != "DE" {
+		t.Errorf("NormalizeRule country match = %q, want DE", got.Match)
+	}
+
+	invalid := []saving.LinkRule{
+		{Kind: "device", Match: "windows", TargetURL: "https://example.com"},
+		{Kind: "country", Match: "DEU", TargetURL: "https://example.com"},
+		{Kind: "language", Match: "de", TargetURL: "https://example.com"},
+		{Kind: "device", Match: "ios", TargetURL: "not a url"},
+	}
+
+	for _, rule := range invalid {
+		if err := ValidateRule(NormalizeRule(rule)); !errors.Is(err, ErrRuleInvalid) {
+			t.Errorf("ValidateRule(%+v) = %v, want ErrRuleInvalid", rule, err)
+		}
+	}
+}
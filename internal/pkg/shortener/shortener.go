@@ -2,31 +2,273 @@ package shortener
 
 import (
 	"2links/internal/pkg/saving"
+	"errors"
 	"fmt"
-	"math/rand"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
 )
 
-const symbols = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+const (
+	symbols = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-func СreateShortLink(Db *saving.DB, id int64, longlink string) (string, error) {
-	var newlink string
-	res := true
-	for res != false {
-		for range 4 {
-			newlink += string(symbols[rand.Intn(len(symbols))])
+	DefaultLifetimeDays    = 30
+	DefaultMaxLifetimeDays = 730
+
+	aliasMinLength = 3
+	aliasMaxLength = 32
+
+	maxGenerateAttempts = 10
+
+	passwordMinLength = 4
+
+	dateLayout = "02-01-2006"
+	timeLayout = "15:04"
+)
+
+var (
+	ErrInvalidExpiry = errors.New("Invalid expiry date")
+	ErrAliasInvalid  = errors.New("Invalid alias")
+	ErrAliasReserved = errors.New("Alias is reserved")
+	ErrAliasTaken    = errors.New("Alias is already taken")
+	ErrPasswordShort = errors.New("Password is too short")
+	ErrInvalidDate   = errors.New("Invalid date")
+
+	DefaultLocation = time.FixedZone("MSK", 3*60*60)
+
+	aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+	redirectStatuses = map[int]bool{
+		http.StatusMovedPermanently:  true,
+		http.StatusFound:             true,
+		http.StatusTemporaryRedirect: true,
+		http.StatusPermanentRedirect: true,
+	}
+
+	reservedAliases = map[string]bool{
+		"api":      true,
+		"qr":       true,
+		"admin":    true,
+		"healthz":  true,
+		"readyz":   true,
+		"metrics":  true,
+		"telegram": true,
+	}
+)
+
+// Config sets how links are created: the generator of their codes and their
+// default and maximum lifetimes in days.
+type Config struct {
+	Generator       CodeGenerator
+	LifetimeDays    int
+	MaxLifetimeDays int
+}
+
+type Shortener struct {
+	db  saving.Store
+	cfg Config
+}
+
+func New(db saving.Store, cfg Config) *Shortener {
+	if cfg.Generator == nil {
+		cfg.Generator = &RandomGenerator{Length: 4}
+	}
+
+	if cfg.LifetimeDays <= 0 {
+		cfg.LifetimeDays = DefaultLifetimeDays
+	}
+
+	if cfg.MaxLifetimeDays <= 0 {
+		cfg.MaxLifetimeDays = DefaultMaxLifetimeDays
+	}
+
+	return &Shortener{db: db, cfg: cfg}
+}
+
+// CreateShortLink saves the link under its ShortURL, or under a generated
+// code when ShortURL is empty, and returns the code.
+func (s *Shortener) CreateShortLink(link saving.NewLink) (string, error) {
+	if link.ExpiresAt.IsZero() {
+		link.ExpiresAt = s.DefaultExpiry()
+	}
+
+	if link.ShortURL != "" {
+		if err := ValidateAlias(link.ShortURL); err != nil {
+			return "", err
+		}
+
+		if s.db.LinkInBase(link.ShortURL) {
+			return "", ErrAliasTaken
+		}
+
+		err := s.db.SaveLink(link)
+		if errors.Is(err, saving.ErrShortURLTaken) {
+			return "", ErrAliasTaken
+		} else if err != nil {
+			return "", err
+		}
+
+		return link.ShortURL, nil
+	}
+
+	for range maxGenerateAttempts {
+		newlink, err := s.cfg.Generator.Generate()
+		if err != nil {
+			return "", err
+		}
+
+		if reservedAliases[strings.ToLower(newlink)] {
+			continue
+		}
+
+		link.ShortURL = newlink
+		err = s.db.SaveLink(link)
+		if errors.Is(err, saving.ErrShortURLTaken) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		return newlink, nil
+	}
+
+	return "", fmt.Errorf("Failed to generate a unique short link after %d attempts", maxGenerateAttempts)
+}
+
+func ValidateAlias(alias string) error {
+	if len(alias) < aliasMinLength || len(alias) > aliasMaxLength {
+		return fmt.Errorf("%w: length must be between %d and %d characters", ErrAliasInvalid, aliasMinLength, aliasMaxLength)
+	}
+
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("%w: only latin letters, digits, '-' and '_' are allowed", ErrAliasInvalid)
+	}
+
+	if reservedAliases[strings.ToLower(alias)] {
+		return ErrAliasReserved
+	}
+
+	return nil
+}
+
+func ValidRedirectStatus(status int) bool {
+	return redirectStatuses[status]
+}
+
+func HashLinkPassword(password string) (string, error) {
+	if len(password) < passwordMinLength {
+		return "", fmt.Errorf("%w: at least %d characters required", ErrPasswordShort, passwordMinLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("Failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func CheckLinkPassword(password, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (s *Shortener) DefaultExpiry() time.Time {
+	return time.Now().AddDate(0, 0, s.cfg.LifetimeDays)
+}
+
+func (s *Shortener) MaxLifetimeDays() int {
+	return s.cfg.MaxLifetimeDays
+}
+
+func (s *Shortener) CheckExpiry(newExpiry time.Time) error {
+	differenceInDays := int(time.Until(newExpiry).Hours() / 24)
+	if newExpiry.Before(time.Now()) || differenceInDays > s.cfg.MaxLifetimeDays {
+		return fmt.Errorf("%w: must be in the future and within %d days", ErrInvalidExpiry, s.cfg.MaxLifetimeDays)
+	}
+
+	return nil
+}
+
+func CheckActivation(activatesAt, expiresAt time.Time) error {
+	if !activatesAt.Before(expiresAt) {
+		return fmt.Errorf("%w: activation must be before the link expires", ErrInvalidExpiry)
+	}
+
+	return nil
+}
+
+func ParseDateTime(input string) (time.Time, error) {
+	parts := strings.Fields(input)
+	if len(parts) == 0 || len(parts) > 3 {
+		return time.Time{}, fmt.Errorf("%w: expected DD-MM-YYYY [HH:MM] [timezone]", ErrInvalidDate)
+	}
+
+	loc := DefaultLocation
+	layout, value := dateLayout, parts[0]
+	for _, part := range parts[1:] {
+		if _, err := time.Parse(timeLayout, part); err == nil && layout == dateLayout {
+			layout, value = dateLayout+" "+timeLayout, value+" "+part
+			continue
+		}
+
+		zone, err := parseLocation(part)
+		if err != nil {
+			return time.Time{}, err
+		}
+		loc = zone
+	}
+
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: expected DD-MM-YYYY [HH:MM] [timezone]", ErrInvalidDate)
+	}
+
+	return t, nil
+}
+
+func parseLocation(zone string) (*time.Location, error) {
+	offset := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(zone), "UTC"), "GMT")
+	if offset == "" {
+		return time.UTC, nil
+	}
+
+	if offset[0] == '+' || offset[0] == '-' {
+		hours, minutes, _ := strings.Cut(offset[1:], ":")
+		h, err := strconv.Atoi(hours)
+		if err != nil || h > 14 {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidDate, zone)
+		}
+
+		m := 0
+		if minutes != "" {
+			m, err = strconv.Atoi(minutes)
+			if err != nil || m >= 60 {
+				return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidDate, zone)
+			}
+		}
+
+		seconds := (h*60 + m) * 60
+		if offset[0] == '-' {
+			seconds = -seconds
 		}
-		res = saving.LinkInBase(Db.Db, newlink)
+		return time.FixedZone(zone, seconds), nil
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidDate, zone)
 	}
 
-	saving.SaveLink(Db.Db, id, longlink, newlink, time.Now().Add((24 * time.Hour * 30)))
-	return newlink, nil
+	return loc, nil
 }
 
 func CheckValidacy(link string) bool {
@@ -0,0 +1,156 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testUser int64 = 1
+
+func newTestShortener(t *testing.T, cfg Config) (*Shortener, saving.Store) {
+	t.Helper()
+
+	db := saving.NewMemory()
+	if err := db.AddUser(testUser); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+
+	return New(db, cfg), db
+}
+
+// fixedGenerator returns its codes in order and then repeats the last one.
+type fixedGenerator struct {
+	codes []string
+}
+
+func (g *fixedGenerator) Generate() (string, error) {
+	code := g.codes[0]
+	if len(g.codes) > 1 {
+		g.codes = g.codes[1:]
+	}
+
+	return code, nil
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		want  error
+	}{
+		{"promo-fall", nil},
+		{"A_1", nil},
+		{"ab", ErrAliasInvalid},
+		{"abcdefghijklmnopqrstuvwxyz0123456", ErrAliasInvalid},
+		{"осень", ErrAliasInvalid},
+		{"promo fall", ErrAliasInvalid},
+		{"promo/fall", ErrAliasInvalid},
+		{"api", ErrAliasReserved},
+		{"Healthz", ErrAliasReserved},
+	}
+
+	for _, test := range tests {
+		if err := ValidateAlias(test.alias); !errors.Is(err, test.want) || test.want == nil && err != nil {
+			t.Errorf("ValidateAlias(%q) = %v, want %v", test.alias, err, test.want)
+		}
+	}
+}
+
+func TestCreateShortLink(t *testing.T) {
+	links, db := newTestShortener(t, Config{Generator: &fixedGenerator{codes: []string{"api", "taken", "free"}}, LifetimeDays: 7})
+
+	code, err := links.CreateShortLink(saving.NewLink{UserID: testUser, ShortURL: "taken", OriginalURL: "https://example.com"})
+	if err != nil || code != "taken" {
+		t.Fatalf("CreateShortLink with an alias = %q, %v", code, err)
+	}
+
+	if _, err := links.CreateShortLink(saving.NewLink{UserID: testUser, ShortURL: "taken", OriginalURL: "https://example.org"}); !errors.Is(err, ErrAliasTaken) {
+		t.Errorf("CreateShortLink with a taken alias = %v, want ErrAliasTaken", err)
+	}
+
+	if _, err := links.CreateShortLink(saving.NewLink{UserID: testUser, ShortURL: "admin", OriginalURL: "https://example.org"}); !errors.Is(err, ErrAliasReserved) {
+		t.Errorf("CreateShortLink with a reserved alias = %v, want ErrAliasReserved", err)
+	}
+
+	code, err = links.CreateShortLink(saving.NewLink{UserID: testUser, OriginalURL: "https://example.org"})
+	if err != nil || code != "free" {
+		t.Fatalf("CreateShortLink = %q, %v; want the reserved and taken codes skipped", code, err)
+	}
+
+	link, err := db.GetOriginalURL("free")
+	if err != nil || link.OriginalURL != "https://example.org" {
+		t.Fatalf("GetOriginalURL = %+v, %v", link, err)
+	}
+
+	if days := time.Until(link.ExpiresAt).Hours() / 24; days < 6.9 || days > 7 {
+		t.Errorf("default expiry in %.2f days, want 7", days)
+	}
+
+	if _, err := links.CreateShortLink(saving.NewLink{UserID: testUser, OriginalURL: "https://example.net"}); err == nil {
+		t.Error("CreateShortLink succeeded with only taken codes left")
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	links, _ := newTestShortener(t, Config{MaxLifetimeDays: 10})
+
+	if err := links.CheckExpiry(time.Now().AddDate(0, 0, 5)); err != nil {
+		t.Errorf("CheckExpiry in 5 days = %v", err)
+	}
+
+	for _, expiry := range []time.Time{time.Now().Add(-time.Minute), time.Now().AddDate(0, 0, 12)} {
+		if err := links.CheckExpiry(expiry); !errors.Is(err, ErrInvalidExpiry) {
+			t.Errorf("CheckExpiry(%v) = %v, want ErrInvalidExpiry", expiry, err)
+		}
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	if err := CheckActivation(expiresAt.Add(-time.Minute), expiresAt); err != nil {
+		t.Errorf("CheckActivation before the expiry = %v", err)
+	}
+	if err := CheckActivation(expiresAt, expiresAt); !errors.Is(err, ErrInvalidExpiry) {
+		t.Errorf("CheckActivation at the expiry = %v, want ErrInvalidExpiry", err)
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"31-12-2030", time.Date(2030, 12, 31, 0, 0, 0, 0, DefaultLocation)},
+		{"31-12-2030 18:30", time.Date(2030, 12, 31, 18, 30, 0, 0, DefaultLocation)},
+		{"31-12-2030 18:30 UTC", time.Date(2030, 12, 31, 18, 30, 0, 0, time.UTC)},
+		{"31-12-2030 18:30 UTC+5", time.Date(2030, 12, 31, 13, 30, 0, 0, time.UTC)},
+		{"31-12-2030 18:30 GMT-03:30", time.Date(2030, 12, 31, 22, 0, 0, 0, time.UTC)},
+		{"31-12-2030 Europe/Berlin", time.Date(2030, 12, 30, 23, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		if got, err := ParseDateTime(test.input); err != nil || !got.Equal(test.want) {
+			t.Errorf("ParseDateTime(%q) = %v, %v; want %v", test.input, got, err, test.want)
+		}
+	}
+
+	for _, input := range []string{"", "2030-12-31", "31-12-2030 25:00", "31-12-2030 18:30 UTC+15", "31-12-2030 18:30 Mars/Base", "1 2 3 4"} {
+		if _, err := ParseDateTime(input); !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("ParseDateTime(%q) = %v, want ErrInvalidDate", input, err)
+		}
+	}
+}
+
+func TestLinkPassword(t *testing.T) {
+	if _, err := HashLinkPassword("abc"); !errors.Is(err, ErrPasswordShort) {
+		t.Errorf("HashLinkPassword of a short password = %v, want ErrPasswordShort", err)
+	}
+
+	hash, err := HashLinkPassword("secret")
+	if err != nil {
+		t.Fatalf("HashLinkPassword: %v", err)
+	}
+
+	if !CheckLinkPassword("secret", hash) || CheckLinkPassword("Secret", hash) {
+		t.Error("CheckLinkPassword does not match the hashed password")
+	}
+}
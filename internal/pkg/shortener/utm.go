@@ -0,0 +1,152 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const presetNameMaxLength = 32
+
+var ErrUTMInvalid = errors.New("Invalid UTM parameters")
+
+func ApplyUTM(destination string, utm saving.UTM) string {
+	base, fragment, hasFragment := strings.Cut(destination, "#")
+	path, rawQuery, _ := strings.Cut(base, "?")
+
+	params := []struct{ key, value string }{
+		{"utm_source", utm.Source},
+		{"utm_medium", utm.Medium},
+		{"utm_campaign", utm.Campaign},
+		{"utm_term", utm.Term},
+		{"utm_content", utm.Content},
+	}
+
+	replaced := make(map[string]bool)
+	for _, param := range params {
+		if param.value != "" {
+			replaced[param.key] = true
+		}
+	}
+
+	var query []string
+	for _, part := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(part, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+
+		if part != "" && !replaced[key] {
+			query = append(query, part)
+		}
+	}
+
+	for _, param := range params {
+		if param.value != "" {
+			query = append(query, param.key+"="+url.QueryEscape(param.value))
+		}
+	}
+
+	result := path
+	if len(query) > 0 {
+		result += "?" + strings.Join(query, "&")
+	}
+
+	if hasFragment {
+		result += "#" + fragment
+	}
+
+	return result
+}
+
+func ParseUTM(input string) (saving.UTM, string, error) {
+	var utm saving.UTM
+	var presetName string
+	fields, err := splitUTM(input)
+	if err != nil {
+		return utm, "", err
+	}
+
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return utm, "", fmt.Errorf("%w: expected key=value, got %q", ErrUTMInvalid, field)
+		}
+
+		switch strings.TrimPrefix(strings.ToLower(key), "utm_") {
+		case "source":
+			utm.Source = value
+		case "medium":
+			utm.Medium = value
+		case "campaign":
+			utm.Campaign = value
+		case "term":
+			utm.Term = value
+		case "content":
+			utm.Content = value
+		case "preset":
+			presetName = value
+		default:
+			return utm, "", fmt.Errorf("%w: unknown parameter %q", ErrUTMInvalid, key)
+		}
+	}
+
+	if err := ValidateUTM(utm); err != nil {
+		return utm, "", err
+	}
+
+	if err := ValidatePresetName(presetName); presetName != "" && err != nil {
+		return utm, "", err
+	}
+
+	return utm, presetName, nil
+}
+
+// splitUTM splits input into pairs separated by spaces, '&' or new lines. A
+// value with spaces is put in double quotes: campaign="fall sale".
+func splitUTM(input string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == '&' || r == '\n' || r == '\r' || r == ' ' || r == '\t'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrUTMInvalid)
+	}
+
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	return fields, nil
+}
+
+func ValidateUTM(utm saving.UTM) error {
+	if utm.Source == "" {
+		return fmt.Errorf("%w: utm_source is required", ErrUTMInvalid)
+	}
+
+	return nil
+}
+
+func ValidatePresetName(name string) error {
+	if name == "" || len(name) > presetNameMaxLength || strings.ContainsAny(name, ": ") {
+		return fmt.Errorf("%w: preset name must be 1 to %d bytes without spaces or ':'", ErrUTMInvalid, presetNameMaxLength)
+	}
+
+	return nil
+}
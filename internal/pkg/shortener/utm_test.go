@@ -0,0 +1,61 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"testing"
+)
+
+func TestApplyUTM(t *testing.T) {
+	tests := []struct {
+		destination string
+		utm         saving.UTM
+		want        string
+	}{
+		{"https://example.com", saving.UTM{Source: "tg"}, "https://example.com?utm_source=tg"},
+		{"https://example.com/page?id=1", saving.UTM{Source: "tg", Medium: "post"}, "https://example.com/page?id=1&utm_source=tg&utm_medium=post"},
+		{"https://example.com/?utm_source=old&id=1", saving.UTM{Source: "tg"}, "https://example.com/?id=1&utm_source=tg"},
+		{"https://example.com/?utm_medium=email", saving.UTM{Source: "tg"}, "https://example.com/?utm_medium=email&utm_source=tg"},
+		{"https://example.com/#top", saving.UTM{Source: "tg", Campaign: "fall sale"}, "https://example.com/?utm_source=tg&utm_campaign=fall+sale#top"},
+		{"https://example.com/?utm%5Fsource=old", saving.UTM{Source: "tg"}, "https://example.com/?utm_source=tg"},
+		{"https://example.com/?a=1", saving.UTM{}, "https://example.com/?a=1"},
+	}
+
+	for _, test := range tests {
+		if got := ApplyUTM(test.destination, test.utm); got != test.want {
+			t.Errorf("ApplyUTM(%q, %+v) = %q, want %q", test.destination, test.utm, got, test.want)
+		}
+	}
+}
+
+func TestParseUTM(t *testing.T) {
+	tests := []struct {
+		input  string
+		utm    saving.UTM
+		preset string
+	}{
+		{"source=tg", saving.UTM{Source: "tg"}, ""},
+		{"source=tg medium=post campaign=fall", saving.UTM{Source: "tg", Medium: "post", Campaign: "fall"}, ""},
+		{"utm_source=tg&utm_medium=post", saving.UTM{Source: "tg", Medium: "post"}, ""},
+		{"source=tg\ncampaign=fall\r\nterm=shoes", saving.UTM{Source: "tg", Campaign: "fall", Term: "shoes"}, ""},
+		{`source=tg campaign="fall sale"`, saving.UTM{Source: "tg", Campaign: "fall sale"}, ""},
+		{`source=tg content="a & b" preset=autumn`, saving.UTM{Source: "tg", Content: "a & b"}, "autumn"},
+		{"SOURCE=tg  Medium=post", saving.UTM{Source: "tg", Medium: "post"}, ""},
+	}
+
+	for _, test := range tests {
+		utm, preset, err := ParseUTM(test.input)
+		if err != nil || utm != test.utm || preset != test.preset {
+			t.Errorf("ParseUTM(%q) = %+v, %q, %v; want %+v, %q", test.input, utm, preset, err, test.utm, test.preset)
+		}
+	}
+
+	for _, input := range []string{
+		"", "medium=post", "source", "source=", `source=""`, "source=tg color=red", `source=tg campaign="fall sale`,
+		"source=tg preset=a:b",
+	} {
+		if _, _, err := ParseUTM(input); !errors.Is(err, ErrUTMInvalid) {
+			t.Errorf("ParseUTM(%q) = %v, want ErrUTMInvalid", input, err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+const (
+	MaxVariants      = 10
+	MaxVariantWeight = 100
+)
+
+var ErrVariantInvalid = errors.New("Invalid link variant")
+
+func ValidateVariant(variant saving.LinkVariant) error {
+	if variant.Weight < 1 || variant.Weight > MaxVariantWeight {
+		return fmt.Errorf("%w: weight must be between 1 and %d", ErrVariantInvalid, MaxVariantWeight)
+	}
+
+	if !CheckValidacy(variant.TargetURL) {
+		return fmt.Errorf("%w: target URL is not valid", ErrVariantInvalid)
+	}
+
+	return nil
+}
+
+func PickVariant(variants []saving.LinkVariant, stickyID int) (saving.LinkVariant, bool) {
+	total := 0
+	for _, variant := range variants {
+		if variant.ID == stickyID {
+			return variant, true
+		}
+		total += variant.Weight
+	}
+
+	if total <= 0 {
+		return saving.LinkVariant{}, false
+	}
+
+	n := rand.Intn(total)
+	for _, variant := range variants {
+		if n < variant.Weight {
+			return variant, true
+		}
+		n -= variant.Weight
+	}
+
+	return saving.LinkVariant{}, false
+}
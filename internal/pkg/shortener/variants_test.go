@@ -0,0 +1,51 @@
+package shortener
+
+import (
+	"2links/internal/pkg/saving"
+	"errors"
+	"testing"
+)
+
+func TestValidateVariant(t *testing.T) {
+	if err := ValidateVariant(saving.LinkVariant{TargetURL: "https://example.com/a", Weight: 50}); err != nil {
+		t.Errorf("ValidateVariant = %v", err)
+	}
+
+	for _, variant := range []saving.LinkVariant{
+		{TargetURL: "https://example.com/a", Weight: 0},
+		{TargetURL: "https://example.com/a", Weight: MaxVariantWeight + 1},
+		{TargetURL: "not a url", Weight: 1},
+	} {
+		if err := ValidateVariant(variant); !errors.Is(err, ErrVariantInvalid) {
+			t.Errorf("ValidateVariant(%+v) = %v, want ErrVariantInvalid", variant, err)
+		}
+	}
+}
+
+func TestPickVariant(t *testing.T) {
+	if _, ok := PickVariant(nil, 0); ok {
+		t.Error("PickVariant picked from no variants")
+	}
+
+	variants := []saving.LinkVariant{
+		{ID: 1, TargetURL: "https://example.com/a", Weight: 3},
+		{ID: 2, TargetURL: "https://example.com/b", Weight: 1},
+	}
+
+	if variant, ok := PickVariant(variants, 2); !ok || variant.ID != 2 {
+		t.Errorf("PickVariant with a sticky variant = %+v, %t; want variant 2", variant, ok)
+	}
+
+	picked := make(map[int]int)
+	for range 4000 {
+		variant, ok := PickVariant(variants, 99)
+		if !ok {
+			t.Fatal("PickVariant picked nothing")
+		}
+		picked[variant.ID]++
+	}
+
+	if picked[1] < 2700 || picked[1] > 3300 || picked[1]+picked[2] != 4000 {
+		t.Errorf("PickVariant picked %v, want about 3000 of variant 1", picked)
+	}
+}